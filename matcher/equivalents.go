@@ -0,0 +1,155 @@
+package matcher
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Equivalents - groups of equivalent tokens, e.g. "Ltd" and "Limited", see Normalizer
+// every token of a group is replaced by the canonical token of the group, the first one which is not punctuation.
+// A group can be a legal suffix such as "Ltd", which is optional in a supplier name with Options.OptionalSuffix.
+type Equivalents struct {
+	canonical map[string]string // the folded token to the canonical token of its group
+	symbols   map[string]string // the punctuation tokens such as "&" to the canonical token of their group
+	suffix    map[string]bool   // the canonical tokens of the legal suffixes
+}
+
+// NewEquivalents - an empty table of equivalent tokens
+func NewEquivalents() *Equivalents {
+	return &Equivalents{
+		canonical: make(map[string]string),
+		symbols:   make(map[string]string),
+		suffix:    make(map[string]bool),
+	}
+}
+
+// DefaultEquivalents - the usual company forms and the ampersand, Co/Company and &/and are not legal suffixes
+func DefaultEquivalents() *Equivalents {
+	e := NewEquivalents()
+	e.Add(true, "ltd", "limited")
+	e.Add(true, "pty", "proprietary")
+	e.Add(true, "inc", "incorporated")
+	e.Add(true, "corp", "corporation")
+	e.Add(true, "llc")
+	e.Add(true, "plc")
+	e.Add(true, "gmbh")
+	e.Add(false, "co", "company")
+	e.Add(false, "and", "&")
+	return e
+}
+
+// LoadEquivalents - load the table of equivalent tokens from a CSV file with a group of single word tokens per record
+// the first field of a record is "legal" for a legal suffix or "word" otherwise, e.g. "legal,ltd,limited" or "word,and,&"
+func LoadEquivalents(path string) (e *Equivalents, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	e = NewEquivalents()
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		kind := strings.TrimSpace(record[0])
+		if kind != "legal" && kind != "word" || len(record) < 2 {
+			return nil, fmt.Errorf("%s:%d: invalid equivalent tokens: %q", path, line, strings.Join(record, ","))
+		}
+		e.Add(kind == "legal", record[1:]...)
+	}
+	return e, nil
+}
+
+// Add - add a group of equivalent tokens, suffix tells whether they are a legal suffix
+func (e *Equivalents) Add(suffix bool, tokens ...string) {
+	canonical := ""
+	for _, token := range tokens {
+		token = foldToken(token)
+		if token != "" && !isPunctToken(token) {
+			canonical = token
+			break
+		}
+	}
+	if canonical == "" { // a group of punctuation only can't be a token
+		return
+	}
+	for _, token := range tokens {
+		token = foldToken(token)
+		if token == "" {
+			continue
+		}
+		if isPunctToken(token) {
+			e.symbols[token] = canonical
+			continue
+		}
+		e.canonical[token] = canonical
+	}
+	if suffix {
+		e.suffix[canonical] = true
+	}
+}
+
+// replaceSymbols - replace the punctuation tokens of the groups by their canonical token before punctuation is removed
+func (e *Equivalents) replaceSymbols(s string) string {
+	for symbol, canonical := range e.symbols {
+		s = strings.ReplaceAll(s, symbol, " "+canonical+" ")
+	}
+	return s
+}
+
+// replace - the canonical token of the group of the token, the token itself if it is in no group
+func (e *Equivalents) replace(token string, folded bool) string {
+	key := token
+	if !folded {
+		key = foldToken(token)
+	}
+	if canonical, ok := e.canonical[key]; ok {
+		return canonical
+	}
+	return token
+}
+
+// isSuffix - whether the normalized token is a legal suffix
+func (e *Equivalents) isSuffix(token string) bool {
+	return e != nil && e.suffix[token]
+}
+
+// trimSuffix - the tokens without the legal suffixes at the end, nil if there is no suffix or only suffixes
+func (e *Equivalents) trimSuffix(tokens []string) []string {
+	end := len(tokens)
+	for end > 0 && e.isSuffix(tokens[end-1]) {
+		end--
+	}
+	if end == len(tokens) || end == 0 {
+		return nil
+	}
+	return tokens[:end]
+}
+
+// foldToken - the token compared with the tokens of the groups
+func foldToken(token string) string {
+	return cases.Fold().String(norm.NFKC.String(strings.TrimSpace(token)))
+}
+
+func isPunctToken(token string) bool {
+	for _, r := range token {
+		if !unicode.IsPunct(r) && !unicode.IsSymbol(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,117 @@
+package matcher
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizer_TokensEquivalents(t *testing.T) {
+	normalizer := DefaultNormalizer()
+	normalizer.Equivalents = DefaultEquivalents()
+	tests := []struct {
+		normalizer Normalizer
+		text       string
+		want       []string
+	}{
+		{normalizer: normalizer, text: "Smith & Co. Proprietary Limited", want: []string{"smith", "and", "co", "pty", "ltd"}},
+		{normalizer: normalizer, text: "SMITH AND COMPANY PTY. LTD.", want: []string{"smith", "and", "co", "pty", "ltd"}},
+		{normalizer: normalizer, text: "Smith&Jones Inc", want: []string{"smith", "and", "jones", "inc"}},
+		{normalizer: Normalizer{Equivalents: DefaultEquivalents()}, text: "Demo Limited", want: []string{"Demo", "ltd"}},
+		{normalizer: DefaultNormalizer(), text: "Smith & Co. Limited", want: []string{"smith", "co", "limited"}},
+	}
+	for _, tt := range tests {
+		if got := tt.normalizer.Tokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokens(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEquivalents_trimSuffix(t *testing.T) {
+	e := DefaultEquivalents()
+	tests := []struct {
+		tokens []string
+		want   []string
+	}{
+		{tokens: []string{"demo", "co", "pty", "ltd"}, want: []string{"demo", "co"}},
+		{tokens: []string{"demo", "inc"}, want: []string{"demo"}},
+		{tokens: []string{"demo", "co"}, want: nil},
+		{tokens: []string{"pty", "ltd"}, want: nil},
+	}
+	for _, tt := range tests {
+		if got := e.trimSuffix(tt.tokens); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("trimSuffix(%v) = %v, want %v", tt.tokens, got, tt.want)
+		}
+	}
+}
+
+func TestLoadEquivalents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "equivalents.csv")
+	if err := os.WriteFile(path, []byte("legal,BV\nword,+,and,plus\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	e, err := LoadEquivalents(path)
+	if err != nil {
+		t.Fatalf("LoadEquivalents() error = %v", err)
+	}
+	normalizer := DefaultNormalizer()
+	normalizer.Equivalents = e
+	want := []string{"a", "and", "b", "bv"}
+	if got := normalizer.Tokens("A plus B bv"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Tokens() = %v, want %v", got, want)
+	}
+	if got := e.trimSuffix(normalizer.Tokens("A + B BV")); !reflect.DeepEqual(got, want[:3]) {
+		t.Errorf("trimSuffix() = %v, want %v", got, want[:3])
+	}
+
+	if err := os.WriteFile(path, []byte("legal,ltd,limited\nsuffix,pty\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadEquivalents(path); err == nil {
+		t.Errorf("LoadEquivalents() error = nil, want invalid equivalent tokens")
+	}
+}
+
+func TestFindSupplierName_legalForms(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName\n1,Another Company Ltd\n2,Demo Company Pty Ltd\n")
+	legalForms := DefaultOptions()
+	legalForms.Normalizer.Equivalents = DefaultEquivalents()
+	optionalSuffix := legalForms
+	optionalSuffix.OptionalSuffix = true
+	tests := []struct {
+		name    string
+		invoice string
+		opts    Options
+		want    string
+	}{
+		{name: "exact forms", invoice: "DEMO COMPANY PTY LTD\n", opts: DefaultOptions(), want: "2"},
+		{name: "other forms", invoice: "DEMO CO. PROPRIETARY LIMITED\n", opts: DefaultOptions(), want: ""},
+		{name: "equivalent forms", invoice: "DEMO CO. PROPRIETARY LIMITED\n", opts: legalForms, want: "2"},
+		{name: "suffix required", invoice: "Demo Company\nInvoice\n", opts: legalForms, want: ""},
+		{name: "optional suffix", invoice: "Demo Company\nInvoice\n", opts: optionalSuffix, want: "2"},
+		{name: "optional suffix only", invoice: "Demo\nPty Ltd\n", opts: optionalSuffix, want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoiceFilePath := writeInvoiceFile(t, tt.invoice)
+			if _, err := BuildIndex(supplierNameFilePath, tt.opts); err != nil {
+				t.Fatalf("BuildIndex() error = %v", err)
+			}
+			for _, find := range []func(context.Context, string, string, Options) (*MatchResult, SupplierFileStats, error){FindSupplierName, FindSupplierNameV2} {
+				got, _, err := find(context.Background(), invoiceFilePath, supplierNameFilePath, tt.opts)
+				if err != nil {
+					t.Fatalf("find() error = %v", err)
+				}
+				gotId := ""
+				if got != nil {
+					gotId = got.SupplierId
+				}
+				if gotId != tt.want {
+					t.Errorf("find() = %v, want supplier %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
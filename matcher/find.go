@@ -0,0 +1,181 @@
+// Package matcher finds the supplier of an invoice by matching the words of the invoice
+// against a list of supplier names.
+package matcher
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+// NewPages - group the words of an invoice by page and prepare them for searching with the normalizer and max distance of opts
+func NewPages(words []*Word, opts Options) (pages []*Page) {
+	pages = groupInvoiceWords(words)
+	for _, page := range pages {
+		sortWordsInPage(page)
+		normalizeWordsInPage(page, opts.Normalizer)
+		page.maxDistance = opts.MaxDistance
+		page.matchMerged = opts.MatchMerged
+		page.geometry = opts.Geometry
+		page.optSuffix = opts.OptionalSuffix
+		buildJoinMapInPage(page, opts.MaxJoin)
+		buildWordMapInPage(page)
+		buildWordMapV2InPage(page)
+		if opts.MatchIdentifiers {
+			buildIdentifierMapInPage(page)
+		}
+	}
+	return
+}
+
+// FindSupplierName - find the supplier name from input files
+// if several supplier names match, the one listed first in the supplier name file is returned regardless of the worker num.
+// return nil if the supplier name is not found, or ctx.Err() if ctx is done before the search completes.
+// stats counts the supplier name lines skipped with opts.Lenient until the search stopped.
+func FindSupplierName(ctx context.Context, invoiceFilePath, supplierNameFilePath string, opts Options) (result *MatchResult, stats SupplierFileStats, err error) {
+	results, stats, err := findSupplierNames(ctx, invoiceFilePath, supplierNameFilePath, opts, false)
+	if err != nil {
+		return nil, stats, err
+	}
+	return firstMatchResult(results), stats, nil
+}
+
+// FindSupplierNames - find all the supplier names matching the invoice from input files
+// the results are ranked by score and limited to opts.TopK, return an empty list if no supplier name is found.
+// stats counts the supplier name lines skipped with opts.Lenient.
+func FindSupplierNames(ctx context.Context, invoiceFilePath, supplierNameFilePath string, opts Options) (results []*MatchResult, stats SupplierFileStats, err error) {
+	results, stats, err = findSupplierNames(ctx, invoiceFilePath, supplierNameFilePath, opts, true)
+	if err != nil {
+		return nil, stats, err
+	}
+	return rankMatchResults(results, opts.TopK), stats, nil
+}
+
+// findSupplierNames - match the supplier names with a pool of workers
+// if all is false the search stops once every supplier listed before the first match is checked
+func findSupplierNames(ctx context.Context, invoiceFilePath, supplierNameFilePath string, opts Options, all bool) (results []*MatchResult, stats SupplierFileStats, err error) {
+	if err = opts.validate(); err != nil {
+		return nil, stats, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, stats, err
+	}
+
+	// preprocess the invoice file
+	words, err := LoadInvoice(invoiceFilePath, opts.InvoiceFormat)
+	if err != nil {
+		return nil, stats, err
+	}
+	pages := NewPages(words, opts)
+
+	// loaderCtx is canceled once a worker finds a supplier name, so no supplier after it is loaded.
+	// The suppliers loaded before it are still checked by the workers, which makes the first match deterministic.
+	loaderCtx, cancelLoader := context.WithCancel(ctx)
+	defer cancelLoader()
+
+	// preprocess the supplier name file
+	supplierChan, loaderErrChan, loaderStats, err := loadSupplierNameFile(loaderCtx, supplierNameFilePath, opts)
+	if err != nil {
+		return nil, stats, err
+	}
+
+	var wg sync.WaitGroup
+	found := make(chan *MatchResult)
+	firstMatchLine := int64(math.MaxInt64)
+	// send worker job
+	for i := uint64(0); i < opts.WorkerNum; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runWorker(ctx, pages, supplierChan, found, &firstMatchLine)
+		}()
+	}
+	go func() {
+		// wait for all worker complete
+		wg.Wait()
+		close(found)
+	}()
+	results = make([]*MatchResult, 0)
+	for result := range found {
+		results = append(results, result)
+		if !all && int64(result.SupplierLine) < atomic.LoadInt64(&firstMatchLine) {
+			atomic.StoreInt64(&firstMatchLine, int64(result.SupplierLine))
+			cancelLoader()
+		}
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, stats, err
+	}
+	if !all && len(results) > 0 {
+		// the loader is canceled, the errors of the lines after the match are ignored
+		<-loaderErrChan
+		return results, *loaderStats, nil
+	}
+	if err = <-loaderErrChan; err != nil {
+		return nil, *loaderStats, err
+	}
+	return results, *loaderStats, nil
+}
+
+// runWorker - run worker to match the supplier names until supplierChan is drained or ctx is done
+// every matched supplier name is sent to found, the suppliers listed after firstMatchLine are skipped
+func runWorker(ctx context.Context, pages []*Page, supplierChan chan *Supplier, found chan<- *MatchResult, firstMatchLine *int64) {
+	for supplier := range supplierChan {
+		if ctx.Err() != nil { // stop early if canceled
+			return
+		}
+		if int64(supplier.Line) > atomic.LoadInt64(firstMatchLine) { // an earlier supplier has matched
+			continue
+		}
+		result := SearchSupplierFromPageV2(pages, supplier)
+		if result = matchIdentifiersInPages(pages, supplier, result); result != nil {
+			found <- result
+		}
+	}
+}
+
+// FindSupplierNameV2 - find the supplier name from input files with the index built by BuildIndex
+// if several supplier names match, the one listed first in the supplier name file is returned.
+// The tokens of the supplier names are looked up in the index without opts.MaxDistance, so at least
+// opts.MinTokenFraction of them have to match exactly, see filterPotentialSuppliersForPage.
+// return nil if the supplier name is not found, or ctx.Err() if ctx is done before the search completes.
+// A process searching many invoices should open the index once with OpenIndex instead.
+// stats counts the supplier name lines skipped with opts.Lenient if a stale index is built again.
+func FindSupplierNameV2(ctx context.Context, invoiceFilePath, supplierNameFilePath string, opts Options) (result *MatchResult, stats SupplierFileStats, err error) {
+	index, stats, err := openIndex(supplierNameFilePath, opts)
+	if err != nil {
+		return nil, stats, err
+	}
+	defer index.Close()
+	result, err = index.FindSupplierName(ctx, invoiceFilePath, opts)
+	return result, stats, err
+}
+
+// FindSupplierNamesV2 - find all the supplier names matching the invoice from input files with the index built by BuildIndex
+// the results are ranked by score and limited to opts.TopK, return an empty list if no supplier name is found.
+// stats counts the supplier name lines skipped with opts.Lenient if a stale index is built again.
+func FindSupplierNamesV2(ctx context.Context, invoiceFilePath, supplierNameFilePath string, opts Options) (results []*MatchResult, stats SupplierFileStats, err error) {
+	index, stats, err := openIndex(supplierNameFilePath, opts)
+	if err != nil {
+		return nil, stats, err
+	}
+	defer index.Close()
+	results, err = index.FindSupplierNames(ctx, invoiceFilePath, opts)
+	return results, stats, err
+}
+
+// openIndex - open the index of the supplier name file, built again first if it is stale and opts.RebuildStaleIndex is set
+// stats is the one of the build, zero if the index is not built again
+func openIndex(supplierNameFilePath string, opts Options) (index *Index, stats SupplierFileStats, err error) {
+	index, err = OpenIndex(supplierNameFilePath, opts)
+	if err == nil || !opts.RebuildStaleIndex || !errors.Is(err, ErrStaleIndex) {
+		return index, stats, err
+	}
+	if stats, err = BuildIndex(supplierNameFilePath, opts); err != nil {
+		return nil, stats, err
+	}
+	index, err = OpenIndex(supplierNameFilePath, opts)
+	return index, stats, err
+}
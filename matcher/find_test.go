@@ -0,0 +1,243 @@
+package matcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	testInvoiceFilePath      = "../invoice.txt"
+	testHocrInvoiceFilePath  = "../invoice.hocr"
+	testAltoInvoiceFilePath  = "../invoice.alto.xml"
+	testSupplierNameFilePath = "../suppliernames.txt"
+)
+
+func TestFindSupplierName(t *testing.T) {
+	want := &MatchResult{
+		SupplierId:   "3153303",
+		SupplierName: "Demo Company",
+		SupplierLine: 47,
+		PageId:       1,
+		Words: []*Word{
+			{Word: "Demo", PosId: 0, PageId: 1, LineId: 4, WordId: 31, CspanId: 19, Left: 4.04, Top: 13.0, Width: 4.63, Height: 1.03, Right: 8.67},
+			{Word: "Company", PosId: 1, PageId: 1, LineId: 4, WordId: 32, CspanId: 19, Left: 9.29, Top: 12.97, Width: 7.61, Height: 1.33, Right: 16.9},
+		},
+		Distances: []float64{0, 0},
+		Strategy:  StrategyBinarySearch,
+	}
+	want.Score = scoreMatch(2, want.Words)
+	got, _, err := FindSupplierName(context.Background(), testInvoiceFilePath, testSupplierNameFilePath, DefaultOptions())
+	if err != nil {
+		t.Fatalf("FindSupplierName() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSupplierName() = %v, want %v", got, want)
+	}
+}
+
+func TestFindSupplierNameV2(t *testing.T) {
+	supplierNameFilePath := copySupplierNameFile(t)
+	if _, err := BuildIndex(supplierNameFilePath, DefaultOptions()); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	want := &MatchResult{
+		SupplierId:   "3153303",
+		SupplierName: "Demo Company",
+		SupplierLine: 47,
+		PageId:       1,
+		Words: []*Word{
+			{Word: "Demo", PosId: 0, PageId: 1, LineId: 4, WordId: 31, CspanId: 19, Left: 4.04, Top: 13.0, Width: 4.63, Height: 1.03, Right: 8.67},
+			{Word: "Company", PosId: 1, PageId: 1, LineId: 4, WordId: 32, CspanId: 19, Left: 9.29, Top: 12.97, Width: 7.61, Height: 1.33, Right: 16.9},
+		},
+		Distances: []float64{0, 0},
+		Strategy:  StrategyIndex,
+	}
+	want.Score = scoreMatch(2, want.Words)
+	got, _, err := FindSupplierNameV2(context.Background(), testInvoiceFilePath, supplierNameFilePath, DefaultOptions())
+	if err != nil {
+		t.Fatalf("FindSupplierNameV2() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSupplierNameV2() = %v, want %v", got, want)
+	}
+}
+
+func TestFindSupplierNames(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName\n1,Demo\n2,Demo Company\n3,Another Company\n4,INVOICE\n")
+	if _, err := BuildIndex(supplierNameFilePath, DefaultOptions()); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	opts := DefaultOptions()
+	opts.TopK = 2
+	want := []string{"2", "4"}
+
+	results, _, err := FindSupplierNames(context.Background(), testInvoiceFilePath, supplierNameFilePath, opts)
+	if err != nil {
+		t.Fatalf("FindSupplierNames() error = %v", err)
+	}
+	if got := supplierIds(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSupplierNames() = %v, want %v", got, want)
+	}
+	results, _, err = FindSupplierNamesV2(context.Background(), testInvoiceFilePath, supplierNameFilePath, opts)
+	if err != nil {
+		t.Fatalf("FindSupplierNamesV2() error = %v", err)
+	}
+	if got := supplierIds(results); !reflect.DeepEqual(got, want) {
+		t.Errorf("FindSupplierNamesV2() = %v, want %v", got, want)
+	}
+}
+
+func TestFindSupplierName_deterministic(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("Id,SupplierName\n")
+	for i := 0; i < 2000; i++ {
+		fmt.Fprintf(&buf, "%d,Supplier %d\n", i, i)
+		if i%500 == 499 { // several suppliers match the invoice, the first of them is on line 502
+			fmt.Fprintf(&buf, "%d,Demo\n%d,INVOICE\n%d,Demo Company\n", 10000+i, 20000+i, 30000+i)
+		}
+	}
+	supplierNameFilePath := writeSupplierNameFile(t, buf.String())
+	if _, err := BuildIndex(supplierNameFilePath, DefaultOptions()); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+
+	for _, workerNum := range []uint64{1, 2, 3, 5, 8, 16} {
+		opts := DefaultOptions()
+		opts.WorkerNum = workerNum
+		for i := 0; i < 3; i++ {
+			result, _, err := FindSupplierName(context.Background(), testInvoiceFilePath, supplierNameFilePath, opts)
+			if err != nil {
+				t.Fatalf("FindSupplierName() error = %v", err)
+			}
+			if result == nil || result.SupplierId != "10499" || result.SupplierLine != 502 {
+				t.Fatalf("FindSupplierName() with %d workers = %v, want supplier 10499 on line 502", workerNum, result)
+			}
+			results, _, err := FindSupplierNames(context.Background(), testInvoiceFilePath, supplierNameFilePath, opts)
+			if err != nil {
+				t.Fatalf("FindSupplierNames() error = %v", err)
+			}
+			if got := supplierIds(results); len(got) != 12 || got[0] != "30499" || got[4] != "20499" || got[8] != "10499" {
+				t.Fatalf("FindSupplierNames() with %d workers = %v", workerNum, got)
+			}
+		}
+	}
+	result, _, err := FindSupplierNameV2(context.Background(), testInvoiceFilePath, supplierNameFilePath, DefaultOptions())
+	if err != nil {
+		t.Fatalf("FindSupplierNameV2() error = %v", err)
+	}
+	if result == nil || result.SupplierId != "10499" {
+		t.Errorf("FindSupplierNameV2() = %v, want supplier 10499", result)
+	}
+}
+
+func TestFindSupplierName_deadlineExceeded(t *testing.T) {
+	supplierNameFilePath := copySupplierNameFile(t)
+	if _, err := BuildIndex(supplierNameFilePath, DefaultOptions()); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), -time.Second)
+	defer cancel()
+
+	if got, _, err := FindSupplierName(ctx, testInvoiceFilePath, supplierNameFilePath, DefaultOptions()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FindSupplierName() = %v, %v, want %v", got, err, context.DeadlineExceeded)
+	}
+	if got, _, err := FindSupplierNameV2(ctx, testInvoiceFilePath, supplierNameFilePath, DefaultOptions()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("FindSupplierNameV2() = %v, %v, want %v", got, err, context.DeadlineExceeded)
+	}
+}
+
+func Test_loadSupplierNameFile_canceled(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("Id,SupplierName\n")
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&buf, "%d,Supplier %d\n", i, i)
+	}
+	supplierNameFilePath := writeSupplierNameFile(t, buf.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	supplierChan, _, _, err := loadSupplierNameFile(ctx, supplierNameFilePath, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	count := 0
+	for range supplierChan { // the loader must close the channel instead of reading the whole file
+		count++
+	}
+	if count >= 10000 {
+		t.Errorf("loadSupplierNameFile() loaded %d suppliers after cancel", count)
+	}
+}
+
+func TestFindSupplierName_invalidSupplierFile(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName\n1,Another Company\ninvalid line\n3153303,Demo Company\n")
+
+	_, _, err := FindSupplierName(context.Background(), testInvoiceFilePath, supplierNameFilePath, DefaultOptions())
+	var fileErr *SupplierFileError
+	if !errors.As(err, &fileErr) {
+		t.Fatalf("FindSupplierName() error = %v, want SupplierFileError", err)
+	}
+	if fileErr.File != supplierNameFilePath || fileErr.Line != 3 {
+		t.Errorf("FindSupplierName() error = %v, want line 3 of %s", err, supplierNameFilePath)
+	}
+
+	opts := DefaultOptions()
+	opts.Lenient = true
+	result, stats, err := FindSupplierName(context.Background(), testInvoiceFilePath, supplierNameFilePath, opts)
+	if err != nil {
+		t.Fatalf("FindSupplierName() lenient error = %v", err)
+	}
+	if result == nil || result.SupplierId != "3153303" {
+		t.Errorf("FindSupplierName() lenient = %v, want supplier 3153303", result)
+	}
+	if stats.Skipped != 1 {
+		t.Errorf("FindSupplierName() lenient skipped %d records, want 1", stats.Skipped)
+	}
+	results, stats, err := FindSupplierNames(context.Background(), testInvoiceFilePath, supplierNameFilePath, opts)
+	if err != nil || len(results) != 1 || stats.Skipped != 1 {
+		t.Errorf("FindSupplierNames() lenient = %v, skipped %d, %v, want supplier 3153303, skipped 1", results, stats.Skipped, err)
+	}
+	stats, err = BuildIndex(supplierNameFilePath, opts)
+	if err != nil {
+		t.Fatalf("BuildIndex() lenient error = %v", err)
+	}
+	if stats.Skipped != 1 {
+		t.Errorf("BuildIndex() lenient skipped %d records, want 1", stats.Skipped)
+	}
+}
+
+// supplierIds - the supplier ids of the results in order
+func supplierIds(results []*MatchResult) []string {
+	ids := make([]string, 0, len(results))
+	for _, result := range results {
+		ids = append(ids, result.SupplierId)
+	}
+	return ids
+}
+
+// writeSupplierNameFile - write the supplier name file content to a temp dir
+func writeSupplierNameFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "suppliernames.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// copySupplierNameFile - copy the sample supplier name file to a temp dir so the index files are not written to the repo
+func copySupplierNameFile(t *testing.T) string {
+	t.Helper()
+	content, err := os.ReadFile(testSupplierNameFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeSupplierNameFile(t, string(content))
+}
@@ -0,0 +1,162 @@
+package matcher
+
+import (
+	"sort"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+const (
+	// ocrSubstitutionCost - the cost of substituting strings OCR often confuses, other edits cost 1
+	ocrSubstitutionCost = 0.5
+	// fuzzyMinTokenLen - keys shorter than this only tolerate OCR confusions, otherwise short tokens match almost anything
+	fuzzyMinTokenLen = 4
+	// fuzzyKeyCacheSize - the max number of supplier name token keys whose matches are cached by a page,
+	// the keys after it are matched again every time, so a large supplier name file doesn't grow the cache
+	fuzzyKeyCacheSize = 1 << 14
+)
+
+// ocrConfusions - pairs of lower case strings OCR often confuses with each other
+var ocrConfusions = [][2]string{
+	{"0", "o"},
+	{"1", "l"},
+	{"1", "i"},
+	{"l", "i"},
+	{"5", "s"},
+	{"8", "b"},
+	{"2", "z"},
+	{"rn", "m"},
+	{"cl", "d"},
+	{"vv", "w"},
+}
+
+// keyMatch - a key of the words in a page matching a key of the supplier name tokens
+type keyMatch struct {
+	key      string
+	distance float64
+}
+
+// ocrDistance - the weighted edit distance between a and b
+// insertion, deletion and substitution cost 1, substituting strings in ocrConfusions costs ocrSubstitutionCost
+func ocrDistance(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	la, lb := []rune(strings.ToLower(a)), []rune(strings.ToLower(b))
+	if len(la) != len(ra) || len(lb) != len(rb) { // lower casing changed the length, compare as is
+		la, lb = ra, rb
+	}
+	d := make([][]float64, len(ra)+1)
+	for i := range d {
+		d[i] = make([]float64, len(rb)+1)
+		d[i][0] = float64(i)
+	}
+	for j := range d[0] {
+		d[0][j] = float64(j)
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1.0
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minFloat(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			for _, pair := range ocrConfusions {
+				for _, p := range [][2]string{pair, {pair[1], pair[0]}} {
+					ni, nj := utf8.RuneCountInString(p[0]), utf8.RuneCountInString(p[1])
+					if ni <= i && nj <= j && string(la[i-ni:i]) == p[0] && string(lb[j-nj:j]) == p[1] {
+						d[i][j] = minFloat(d[i][j], d[i-ni][j-nj]+ocrSubstitutionCost)
+					}
+				}
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// maxKeyDistance - the max distance tolerated when matching the key
+func maxKeyDistance(key string, maxDistance float64) float64 {
+	if utf8.RuneCountInString(key) < fuzzyMinTokenLen && maxDistance > ocrSubstitutionCost {
+		return ocrSubstitutionCost
+	}
+	return maxDistance
+}
+
+// matchKey - match the key of an invoice word with the key of the supplier name tokens
+// return the distance between them and whether it is within the max distance of the page
+func (page *Page) matchKey(wordKey, tokensKey string) (distance float64, ok bool) {
+	if wordKey == tokensKey {
+		return 0, true
+	}
+	if page.maxDistance <= 0 || wordKey == "" {
+		return 0, false
+	}
+	maxDistance := maxKeyDistance(tokensKey, page.maxDistance)
+	// every edit changes the length by at most 1 rune at the cost of at least ocrSubstitutionCost
+	lenDiff := utf8.RuneCountInString(wordKey) - utf8.RuneCountInString(tokensKey)
+	if float64(lenDiff) > maxDistance/ocrSubstitutionCost || float64(-lenDiff) > maxDistance/ocrSubstitutionCost {
+		return 0, false
+	}
+	distance = ocrDistance(wordKey, tokensKey)
+	return distance, distance <= maxDistance
+}
+
+// lookupKeys - the keys of the words in the page matching the key of the supplier name tokens, the closest first
+// if joined is set, the keys of the runs of adjacent words in the join map are looked up instead
+func (page *Page) lookupKeys(tokensKey string, joined bool) []keyMatch {
+	if page.maxDistance <= 0 {
+		_, inWordMap := page.WordMap[tokensKey]
+		_, inWordMapV2 := page.WordMapV2[tokensKey]
+		_, inJoinMap := page.joinMap[tokensKey]
+		if !joined && (inWordMap || inWordMapV2) || joined && inJoinMap {
+			return []keyMatch{{key: tokensKey}}
+		}
+		return nil
+	}
+	cacheKey := tokensKey
+	if joined {
+		cacheKey = "\x00" + tokensKey
+	}
+	if cached, ok := page.fuzzyKeys.Load(cacheKey); ok {
+		return cached.([]keyMatch)
+	}
+	matches := make([]keyMatch, 0)
+	collect := func(key string) {
+		if distance, ok := page.matchKey(key, tokensKey); ok {
+			matches = append(matches, keyMatch{key: key, distance: distance})
+		}
+	}
+	switch {
+	case joined:
+		for key := range page.joinMap {
+			collect(key)
+		}
+	case len(page.WordMapV2) > 0:
+		for key := range page.WordMapV2 {
+			collect(key)
+		}
+	default:
+		for key := range page.WordMap {
+			collect(key)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].distance != matches[j].distance {
+			return matches[i].distance < matches[j].distance
+		}
+		return matches[i].key < matches[j].key
+	})
+	if atomic.AddInt32(&page.fuzzyCount, 1) <= fuzzyKeyCacheSize {
+		page.fuzzyKeys.Store(cacheKey, matches)
+	}
+	return matches
+}
+
+func minFloat(values ...float64) float64 {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
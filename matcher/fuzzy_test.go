@@ -0,0 +1,110 @@
+package matcher
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func Test_ocrDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{a: "Company", b: "Company", want: 0},
+		{a: "C0mpany", b: "Company", want: 0.5},
+		{a: "Cornpany", b: "Company", want: 0.5},
+		{a: "Company", b: "Cornpany", want: 0.5},
+		{a: "INVO1CE", b: "INVOICE", want: 0.5},
+		{a: "lnvoice", b: "Invoice", want: 0.5},
+		{a: "Compary", b: "Company", want: 1},
+		{a: "Compny", b: "Company", want: 1},
+		{a: "", b: "Demo", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.a+"/"+tt.b, func(t *testing.T) {
+			if got := ocrDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("ocrDistance() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxDistance_search(t *testing.T) {
+	supplier := &Supplier{Id: "123", SupplierName: "Demo Company"}
+	tests := []struct {
+		name          string
+		maxDistance   float64
+		words         []*Word
+		wantDistances []float64
+		greedy        bool // the two pointer scan takes the first word within the distance instead of the closest one
+	}{
+		{
+			name:          "ocr confusion",
+			maxDistance:   1,
+			words:         []*Word{{Word: "Dern0", LineId: 4, PosId: 0}, {Word: "Cornpany", LineId: 4, PosId: 1}},
+			wantDistances: []float64{1, 0.5},
+		},
+		{
+			name:          "exact word preferred",
+			maxDistance:   1,
+			words:         []*Word{{Word: "Dema", LineId: 3, PosId: 0}, {Word: "Demo", LineId: 3, PosId: 1}, {Word: "Company", LineId: 4, PosId: 0}},
+			wantDistances: []float64{0, 0},
+			greedy:        true,
+		},
+		{
+			name:          "too far",
+			maxDistance:   1,
+			words:         []*Word{{Word: "Dxmx", LineId: 4, PosId: 0}, {Word: "Company", LineId: 4, PosId: 1}},
+			wantDistances: nil,
+		},
+		{
+			name:          "exact",
+			maxDistance:   0,
+			words:         []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Cornpany", LineId: 4, PosId: 1}},
+			wantDistances: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := NewPages(tt.words, Options{Normalizer: DefaultNormalizer(), MaxDistance: tt.maxDistance})
+			results := []*MatchResult{
+				SearchSupplierFromPage(pages, supplier),
+				SearchSupplierFromPageV2(pages, supplier),
+				SearchSupplierFromPageV3([]*SuppliersForPage{{Page: pages[0], Suppliers: []*Supplier{supplier}}}),
+			}
+			for i, result := range results {
+				if i == 0 && tt.greedy {
+					continue
+				}
+				var gotDistances []float64
+				if result != nil {
+					gotDistances = result.Distances
+				}
+				if !reflect.DeepEqual(gotDistances, tt.wantDistances) {
+					t.Errorf("search #%d distances = %v, want %v", i+1, gotDistances, tt.wantDistances)
+				}
+			}
+		})
+	}
+}
+
+func TestPage_lookupKeys_cacheSize(t *testing.T) {
+	pages := NewPages([]*Word{{Word: "Cornpany", LineId: 4, PosId: 0}}, Options{Normalizer: DefaultNormalizer(), MaxDistance: 1})
+	page := pages[0]
+	for i := 0; i < fuzzyKeyCacheSize+100; i++ {
+		page.lookupKeys(fmt.Sprintf("token%d", i), false)
+	}
+	cached := 0
+	page.fuzzyKeys.Range(func(key, value interface{}) bool {
+		cached++
+		return true
+	})
+	if cached > fuzzyKeyCacheSize {
+		t.Errorf("lookupKeys() cached %d keys, want at most %d", cached, fuzzyKeyCacheSize)
+	}
+	// the keys after the cache is full are still matched
+	if got := page.lookupKeys("company", false); len(got) != 1 || got[0].key != "cornpany" {
+		t.Errorf("lookupKeys() = %v, want cornpany", got)
+	}
+}
@@ -0,0 +1,45 @@
+package matcher
+
+import "math"
+
+const (
+	// maxHorizontalGap - the max gap between two words on the same baseline, in line heights
+	maxHorizontalGap = 3.0
+	// maxBaselineShift - the max shift between the bottom of two words on the same baseline, in line heights
+	maxBaselineShift = 0.5
+	// maxVerticalGap - the max gap between a word and the word directly below it, in line heights
+	maxVerticalGap = 1.0
+)
+
+// Bottom - the bottom of the bounding box of the word
+func (w *Word) Bottom() float64 {
+	return w.Top + w.Height
+}
+
+// hasBox - whether the word has a bounding box
+func (w *Word) hasBox() bool {
+	return w.Width > 0 && w.Height > 0
+}
+
+// isNear - whether next can follow prev in a supplier name
+// with geometry, next must be on the same baseline right after prev, or directly below prev within a line height.
+// Otherwise, or if a word has no bounding box, next must be at most one line below prev.
+func (page *Page) isNear(prev, next *Word) bool {
+	if prev == nil {
+		return true
+	}
+	if !page.geometry || !prev.hasBox() || !next.hasBox() {
+		return prev.LineId+1 >= next.LineId
+	}
+	lineHeight := math.Max(prev.Height, next.Height)
+	// same baseline, a small overlap is tolerated for imprecise boxes
+	gap := next.Left - prev.Right
+	if math.Abs(next.Bottom()-prev.Bottom()) <= maxBaselineShift*lineHeight &&
+		gap >= -maxBaselineShift*lineHeight && gap <= maxHorizontalGap*lineHeight {
+		return true
+	}
+	// directly below, the boxes overlap horizontally
+	verticalGap := next.Top - prev.Bottom()
+	return verticalGap >= -maxBaselineShift*lineHeight && verticalGap <= maxVerticalGap*lineHeight &&
+		next.Left < prev.Right && next.Right > prev.Left
+}
@@ -0,0 +1,106 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGeometry_search(t *testing.T) {
+	supplier := &Supplier{Id: "123", SupplierName: "Demo Company"}
+	geometryOpts := Options{Normalizer: DefaultNormalizer(), Geometry: true}
+	demo := &Word{Word: "Demo", LineId: 4, PosId: 0, Left: 4, Top: 13, Width: 4.6, Height: 1, Right: 8.6}
+	tests := []struct {
+		name      string
+		opts      Options
+		words     []*Word
+		wantLines []uint32
+	}{
+		{
+			name: "same baseline",
+			opts: geometryOpts,
+			words: []*Word{demo,
+				{Word: "Company", LineId: 4, PosId: 1, Left: 9.3, Top: 12.9, Width: 7.6, Height: 1.1, Right: 16.9}},
+			wantLines: []uint32{4, 4},
+		},
+		{
+			name: "unrelated column",
+			opts: geometryOpts,
+			words: []*Word{demo,
+				{Word: "Company", LineId: 4, PosId: 1, Left: 40, Top: 13, Width: 7.6, Height: 1, Right: 47.6}},
+			wantLines: nil,
+		},
+		{
+			name: "unrelated column without geometry",
+			opts: Options{Normalizer: DefaultNormalizer()},
+			words: []*Word{demo,
+				{Word: "Company", LineId: 4, PosId: 1, Left: 40, Top: 13, Width: 7.6, Height: 1, Right: 47.6}},
+			wantLines: []uint32{4, 4},
+		},
+		{
+			name: "directly below",
+			opts: geometryOpts,
+			words: []*Word{demo,
+				{Word: "Company", LineId: 5, PosId: 0, Left: 4, Top: 14.3, Width: 7.6, Height: 1, Right: 11.6}},
+			wantLines: []uint32{4, 5},
+		},
+		{
+			name: "below in another column",
+			opts: geometryOpts,
+			words: []*Word{demo,
+				{Word: "Company", LineId: 5, PosId: 0, Left: 40, Top: 14.3, Width: 7.6, Height: 1, Right: 47.6}},
+			wantLines: nil,
+		},
+		{
+			name: "skip the unrelated column",
+			opts: geometryOpts,
+			words: []*Word{demo,
+				{Word: "Company", LineId: 4, PosId: 1, Left: 40, Top: 13, Width: 7.6, Height: 1, Right: 47.6},
+				{Word: "Company", LineId: 5, PosId: 0, Left: 4, Top: 14.3, Width: 7.6, Height: 1, Right: 11.6}},
+			wantLines: []uint32{4, 5},
+		},
+		{
+			name:      "no bounding box",
+			opts:      geometryOpts,
+			words:     []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Company", LineId: 5, PosId: 0}},
+			wantLines: []uint32{4, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := NewPages(tt.words, tt.opts)
+			results := []*MatchResult{
+				SearchSupplierFromPage(pages, supplier),
+				SearchSupplierFromPageV2(pages, supplier),
+				SearchSupplierFromPageV3([]*SuppliersForPage{{Page: pages[0], Suppliers: []*Supplier{supplier}}}),
+			}
+			for i, result := range results {
+				var gotLines []uint32
+				if result != nil {
+					for _, w := range result.Words {
+						gotLines = append(gotLines, w.LineId)
+					}
+				}
+				if !reflect.DeepEqual(gotLines, tt.wantLines) {
+					t.Errorf("search #%d lines = %v, want %v", i+1, gotLines, tt.wantLines)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadInvoiceFile_geometry(t *testing.T) {
+	words, err := LoadInvoiceFile(testInvoiceFilePath)
+	if err != nil {
+		t.Fatalf("LoadInvoiceFile() error = %v", err)
+	}
+	want := Word{Word: "Company", PosId: 1, PageId: 1, LineId: 4, WordId: 32, CspanId: 19, Left: 9.29, Top: 12.97, Width: 7.61, Height: 1.33, Right: 16.9}
+	for _, w := range words {
+		if w.Word == want.Word && w.LineId == want.LineId {
+			if !reflect.DeepEqual(*w, want) {
+				t.Errorf("LoadInvoiceFile() word = %+v, want %+v", *w, want)
+			}
+			return
+		}
+	}
+	t.Errorf("LoadInvoiceFile() word %q not found", want.Word)
+}
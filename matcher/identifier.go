@@ -0,0 +1,247 @@
+package matcher
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// IdentifierType - the type of a hard identifier of a supplier, normalized its own way, see normalizeIdentifier
+type IdentifierType string
+
+const (
+	IdentifierTaxNumber IdentifierType = "tax"    // a tax number such as an ABN or a VAT number, letters and digits
+	IdentifierPhone     IdentifierType = "phone"  // a phone number, its last phoneDigits digits
+	IdentifierEmail     IdentifierType = "email"  // an email address, lower case
+	IdentifierDomain    IdentifierType = "domain" // a website domain without www, lower case
+)
+
+const (
+	// maxIdentifierWords - the max number of adjacent words on a line joined to find an identifier, e.g. "51 824 753 556"
+	maxIdentifierWords = 5
+	// minTaxNumberDigits - the min number of digits of a tax number, so that small numbers on the invoice are ignored
+	minTaxNumberDigits = 8
+	// phoneDigits - the number of digits compared in a phone number, so that country and trunk prefixes are ignored
+	phoneDigits = 9
+	// minPhoneDigits - the min number of digits of a phone number
+	minPhoneDigits = 7
+)
+
+var (
+	emailReg  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	domainReg = regexp.MustCompile(`^(?:[A-Za-z]+://)?(?:www\.)?((?:[A-Za-z0-9-]+\.)+[A-Za-z]{2,})(?:[/:?#].*)?$`)
+)
+
+// Identifier - a normalized identifier of a supplier
+type Identifier struct {
+	Type  IdentifierType `json:"type"`
+	Value string         `json:"value"`
+}
+
+// DefaultIdentifierColumns - the headers of the identifier columns of the supplier name file
+func DefaultIdentifierColumns() map[string]IdentifierType {
+	return map[string]IdentifierType{
+		"TaxNumber": IdentifierTaxNumber,
+		"ABN":       IdentifierTaxNumber,
+		"VAT":       IdentifierTaxNumber,
+		"Phone":     IdentifierPhone,
+		"Email":     IdentifierEmail,
+		"Domain":    IdentifierDomain,
+		"Website":   IdentifierDomain,
+	}
+}
+
+// key - the key of the identifier in the index and the page, it contains a space so that it is never a name token
+func (id Identifier) key() string {
+	return string(id.Type) + " " + id.Value
+}
+
+func (id Identifier) String() string {
+	return string(id.Type) + ":" + id.Value
+}
+
+// parseIdentifier - parse an identifier formatted by Identifier.String
+func parseIdentifier(s string) (id Identifier, err error) {
+	i := strings.IndexByte(s, ':')
+	if i < 0 {
+		return id, fmt.Errorf("invalid identifier %q", s)
+	}
+	return Identifier{Type: IdentifierType(s[:i]), Value: s[i+1:]}, nil
+}
+
+// normalizeIdentifier - the normalized identifier of the text, false if it is not a valid identifier of the type
+func normalizeIdentifier(t IdentifierType, s string) (id Identifier, ok bool) {
+	id.Type = t
+	switch t {
+	case IdentifierTaxNumber:
+		id.Value, ok = normalizeTaxNumber(s)
+	case IdentifierPhone:
+		id.Value, ok = normalizePhone(s)
+	case IdentifierEmail:
+		email := emailReg.FindString(s)
+		id.Value, ok = strings.ToLower(email), email != ""
+	case IdentifierDomain:
+		if strings.Contains(s, "@") { // the domain of an email address
+			if email := emailReg.FindString(s); email != "" {
+				s = email[strings.LastIndexByte(email, '@')+1:]
+			}
+		}
+		match := domainReg.FindStringSubmatch(strings.TrimFunc(s, unicode.IsPunct))
+		if len(match) == 2 {
+			id.Value, ok = strings.ToLower(match[1]), true
+		}
+	}
+	return
+}
+
+// normalizeTaxNumber - the letters and digits of the tax number in upper case, e.g. "GB 123 4567 89" is "GB123456789"
+func normalizeTaxNumber(s string) (string, bool) {
+	var sb strings.Builder
+	digits := 0
+	for _, r := range s {
+		switch {
+		case unicode.IsDigit(r):
+			digits++
+			sb.WriteRune(r)
+		case unicode.IsLetter(r):
+			sb.WriteRune(unicode.ToUpper(r))
+		}
+	}
+	return sb.String(), digits >= minTaxNumberDigits
+}
+
+// normalizePhone - the last phoneDigits digits of the phone number, false if the text is not only a phone number
+func normalizePhone(s string) (string, bool) {
+	var sb strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case strings.ContainsRune("+()-./ ", r):
+		default:
+			return "", false
+		}
+	}
+	digits := sb.String()
+	if len(digits) < minPhoneDigits {
+		return "", false
+	}
+	if len(digits) > phoneDigits {
+		digits = digits[len(digits)-phoneDigits:]
+	}
+	return digits, true
+}
+
+// buildIdentifierMapInPage - find the identifiers printed in the page
+// tax numbers and phone numbers can be split into adjacent words on a line, emails and domains are single words
+func buildIdentifierMapInPage(page *Page) {
+	page.identifierMap = make(map[string][]*Word)
+	add := func(id Identifier, words []*Word) {
+		if _, found := page.identifierMap[id.key()]; !found {
+			page.identifierMap[id.key()] = words
+		}
+	}
+	for i, first := range page.Words {
+		var joined strings.Builder
+		for n := 1; n <= maxIdentifierWords && i+n <= len(page.Words); n++ {
+			w := page.Words[i+n-1]
+			if w.LineId != first.LineId {
+				break
+			}
+			if n > 1 {
+				joined.WriteString(" ")
+			}
+			joined.WriteString(w.Word)
+			words := page.Words[i : i+n]
+			types := []IdentifierType{IdentifierTaxNumber, IdentifierPhone}
+			if n == 1 {
+				types = append(types, IdentifierEmail, IdentifierDomain)
+			}
+			for _, t := range types {
+				text := joined.String()
+				if (t == IdentifierTaxNumber || t == IdentifierPhone) && !isIdentifierText(text) {
+					continue
+				}
+				if id, ok := normalizeIdentifier(t, text); ok {
+					add(id, words)
+				}
+			}
+		}
+	}
+}
+
+// isIdentifierText - whether the text has only digits, upper case letters and separators like a tax or phone number
+func isIdentifierText(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) && !unicode.IsUpper(r) && !strings.ContainsRune("+()-./ ", r) {
+			return false
+		}
+	}
+	return true
+}
+
+// identifierKeys - the keys of the identifiers of the page, see Identifier.key
+func (page *Page) identifierKeys() []string {
+	keys := make([]string, 0, len(page.identifierMap))
+	for key := range page.identifierMap {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// matchIdentifiers - boost the result of the supplier with the identifiers of the supplier printed in the page,
+// or create a result from them if the name of the supplier didn't match, nil if nothing matched.
+// The identifiers of an alias only boost the alias, so that a supplier matched by identifiers is reported once.
+func (page *Page) matchIdentifiers(supplier *Supplier, result *MatchResult) *MatchResult {
+	if page.identifierMap == nil || result == nil && supplier.Alias != "" {
+		return result
+	}
+	var found []Identifier
+	var words []*Word
+	for _, id := range supplier.Identifiers {
+		if idWords, ok := page.identifierMap[id.key()]; ok {
+			found = append(found, id)
+			words = append(words, idWords...)
+		}
+	}
+	if len(found) == 0 {
+		return result
+	}
+	if result == nil {
+		result = newMatchResult(supplier, nil, []wordMatch{{words: sortedWords(words)}}, StrategyIdentifier)
+		result.Score = 0
+	}
+	result.Identifiers = found
+	result.Score += float64(len(found)) * identifierWeight
+	return result
+}
+
+// matchIdentifiersInPages - match the identifiers of the supplier in the page of the result, or in the first page with any
+func matchIdentifiersInPages(pages []*Page, supplier *Supplier, result *MatchResult) *MatchResult {
+	for _, page := range pages {
+		if result != nil && len(page.Words) > 0 && page.Words[0].PageId != result.PageId {
+			continue
+		}
+		if matched := page.matchIdentifiers(supplier, result); matched != nil {
+			return matched
+		}
+	}
+	return result
+}
+
+// sortedWords - the words in reading order without duplicates
+func sortedWords(words []*Word) []*Word {
+	sorted := make([]*Word, 0, len(words))
+	seen := make(map[*Word]bool)
+	for _, w := range words {
+		if !seen[w] {
+			seen[w] = true
+			sorted = append(sorted, w)
+		}
+	}
+	sort.Sort(byPosAndLine(sorted))
+	return sorted
+}
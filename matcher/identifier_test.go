@@ -0,0 +1,145 @@
+package matcher
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func Test_normalizeIdentifier(t *testing.T) {
+	tests := []struct {
+		t      IdentifierType
+		text   string
+		want   string
+		wantOk bool
+	}{
+		{t: IdentifierTaxNumber, text: "51 824 753 556", want: "51824753556", wantOk: true},
+		{t: IdentifierTaxNumber, text: "gb 123 4567 89", want: "GB123456789", wantOk: true},
+		{t: IdentifierTaxNumber, text: "2024", wantOk: false},
+		{t: IdentifierPhone, text: "+1 (111) 222-3333", want: "112223333", wantOk: true},
+		{t: IdentifierPhone, text: "111.222.3333", want: "112223333", wantOk: true},
+		{t: IdentifierPhone, text: "Tel 1112223333", wantOk: false},
+		{t: IdentifierEmail, text: "Billing@Demo.com.au,", want: "billing@demo.com.au", wantOk: true},
+		{t: IdentifierEmail, text: "demo.com.au", wantOk: false},
+		{t: IdentifierDomain, text: "https://www.Demo.com.au/contact", want: "demo.com.au", wantOk: true},
+		{t: IdentifierDomain, text: "billing@demo.com.au", want: "demo.com.au", wantOk: true},
+		{t: IdentifierDomain, text: "Company.", wantOk: false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeIdentifier(tt.t, tt.text)
+		if ok != tt.wantOk || ok && got.Value != tt.want {
+			t.Errorf("normalizeIdentifier(%s, %q) = %q, %v, want %q, %v", tt.t, tt.text, got.Value, ok, tt.want, tt.wantOk)
+		}
+	}
+}
+
+func Test_buildIdentifierMapInPage(t *testing.T) {
+	words, err := LoadInvoice(writeInvoiceFile(t, "Demo Company\nABN: 51 824 753 556\nPhone : 111.222.3333\nbilling@demo.com.au\n"), InvoiceFormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.MatchIdentifiers = true
+	page := NewPages(words, opts)[0]
+	tests := []struct {
+		id   Identifier
+		want []string
+	}{
+		{id: Identifier{Type: IdentifierTaxNumber, Value: "51824753556"}, want: []string{"51", "824", "753", "556"}},
+		{id: Identifier{Type: IdentifierPhone, Value: "112223333"}, want: []string{"111.222.3333"}},
+		{id: Identifier{Type: IdentifierEmail, Value: "billing@demo.com.au"}, want: []string{"billing@demo.com.au"}},
+		{id: Identifier{Type: IdentifierDomain, Value: "demo.com.au"}, want: []string{"billing@demo.com.au"}},
+	}
+	for _, tt := range tests {
+		got := make([]string, 0)
+		for _, w := range page.identifierMap[tt.id.key()] {
+			got = append(got, w.Word)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("identifierMap[%s] = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+func TestFindSupplierName_identifiers(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName,ABN,Phone,Email\n"+
+		"1,Demo Company,51 824 753 556,+1 111 222 3333,\n"+
+		"2,Another Company,,,billing@another.com|accounts@another.com\n")
+	identifiers := DefaultOptions()
+	identifiers.MatchIdentifiers = true
+	tests := []struct {
+		name     string
+		invoice  string
+		opts     Options
+		want     string
+		strategy Strategy
+		ids      []Identifier
+	}{
+		{
+			name:     "identifiers only",
+			invoice:  "INVOICE\nABN: 51 824 753 556\nPhone : 111.222.3333\n",
+			opts:     identifiers,
+			want:     "1",
+			strategy: StrategyIdentifier,
+			ids:      []Identifier{{Type: IdentifierTaxNumber, Value: "51824753556"}, {Type: IdentifierPhone, Value: "112223333"}},
+		},
+		{
+			name:    "identifiers disabled",
+			invoice: "INVOICE\nABN: 51 824 753 556\nPhone : 111.222.3333\n",
+			opts:    DefaultOptions(),
+			want:    "",
+		},
+		{
+			name:    "name and identifier",
+			invoice: "Another Company\nbilling@another.com\n",
+			opts:    identifiers,
+			want:    "2",
+			ids:     []Identifier{{Type: IdentifierEmail, Value: "billing@another.com"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoiceFilePath := writeInvoiceFile(t, tt.invoice)
+			if _, err := BuildIndex(supplierNameFilePath, tt.opts); err != nil {
+				t.Fatalf("BuildIndex() error = %v", err)
+			}
+			for _, find := range []func(context.Context, string, string, Options) (*MatchResult, SupplierFileStats, error){FindSupplierName, FindSupplierNameV2} {
+				got, _, err := find(context.Background(), invoiceFilePath, supplierNameFilePath, tt.opts)
+				if err != nil {
+					t.Fatalf("find() error = %v", err)
+				}
+				if tt.want == "" {
+					if got != nil {
+						t.Errorf("find() = %v, want nil", got)
+					}
+					continue
+				}
+				if got == nil || got.SupplierId != tt.want || !reflect.DeepEqual(got.Identifiers, tt.ids) {
+					t.Fatalf("find() = %+v, want supplier %q with identifiers %v", got, tt.want, tt.ids)
+				}
+				if tt.strategy != "" && got.Strategy != tt.strategy {
+					t.Errorf("find() strategy = %s, want %s", got.Strategy, tt.strategy)
+				}
+			}
+		})
+	}
+}
+
+func TestFindSupplierNames_identifierBoost(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName,Domain\n1,Demo,\n2,Demo Foods,demo.com\n")
+	invoiceFilePath := writeInvoiceFile(t, "Demo\nFoods\nwww.demo.com\n")
+	opts := DefaultOptions()
+	opts.MatchIdentifiers = true
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	for _, find := range []func(context.Context, string, string, Options) ([]*MatchResult, SupplierFileStats, error){FindSupplierNames, FindSupplierNamesV2} {
+		got, _, err := find(context.Background(), invoiceFilePath, supplierNameFilePath, opts)
+		if err != nil {
+			t.Fatalf("find() error = %v", err)
+		}
+		if len(got) != 2 || got[0].SupplierId != "2" || len(got[0].Identifiers) != 1 || got[1].Identifiers != nil {
+			t.Errorf("find() = %v, want supplier 2 boosted by its domain before supplier 1", got)
+		}
+	}
+}
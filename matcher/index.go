@@ -0,0 +1,613 @@
+package matcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// supplierIndex - the inverted index of the supplier names in memory, written in the format of <supplier>.idx by writeBinaryIndex,
+// e.g. for the segment. The index files are built within a memory budget by indexBuilder instead.
+type supplierIndex struct {
+	// Postings - the normalized name tokens and identifier keys to the posting list of their suppliers,
+	// the ascending offsets of the supplier records in <supplier>.indexed
+	Postings map[string][]uint64
+	// TokenCounts - the offset of a supplier record to the number of distinct tokens of its name or alias,
+	// without the legal suffixes if the index is built with Options.OptionalSuffix
+	TokenCounts map[uint64]int
+
+	// Source - the supplier name file the index is built from, checked before searching
+	Source indexSource
+	// IndexedSize, IndexedChecksum - the size and the CRC-32 of <supplier>.indexed, so a mismatched pair of files is detected
+	IndexedSize     uint64
+	IndexedChecksum uint32
+	// Options - the fingerprint of the options the keys are built with, see indexOptionsHash
+	Options [sha256.Size]byte
+}
+
+// BuildIndex - build the index files of the supplier name file
+// it writes <supplier>.indexed with a "line,id,name" or "line,id,name,alias" CSV record per supplier and alias
+// in the order of the supplier name file, and <supplier>.idx with the inverted index of every normalized token of
+// their names in a binary format, see writeBinaryIndex. With opts.MatchIdentifiers the identifiers of a supplier
+// are indexed too, and its records end with the identifiers separated by "|", e.g. "2,1,Demo Company,,tax:51824753556|phone:111222333"
+// stats reports the malformed records skipped with opts.Lenient.
+func BuildIndex(supplierNameFilePath string, opts Options) (stats SupplierFileStats, err error) {
+	// read the source first, an edit of the supplier name file while it is loaded makes the index stale
+	source, err := readIndexSource(supplierNameFilePath)
+	if err != nil {
+		return stats, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // stop the loader if the index can't be written
+	supplierChan, loaderErrChan, loaderStats, err := loadSupplierNameFile(ctx, supplierNameFilePath, opts)
+	if err != nil {
+		return stats, err
+	}
+	if err = writeIndex(supplierNameFilePath, supplierChan, loaderErrChan, opts, source); err != nil {
+		return stats, err
+	}
+	return *loaderStats, nil
+}
+
+// ConvertIndex - convert the index files of the supplier name file from the JSON format of the previous versions
+// to the binary format. The suppliers are read back from <supplier>.indexed, so the supplier name file itself
+// is not needed, and both index files are rewritten with the normalizer and the options of opts.
+// The "id,name" records of the first version are numbered in the order of the file, see sortJsonIndexed.
+func ConvertIndex(supplierNameFilePath string, opts Options) (err error) {
+	idxJson, err := ioutil.ReadFile(fmt.Sprintf("%s.idx", supplierNameFilePath))
+	if err != nil {
+		return err
+	}
+	if !isJsonIndex(idxJson) {
+		return fmt.Errorf("%s.idx is not a JSON index", supplierNameFilePath)
+	}
+	indexedPath := fmt.Sprintf("%s.indexed", supplierNameFilePath)
+	legacy, err := isLegacyIndexed(indexedPath)
+	if err != nil {
+		return err
+	}
+	// the JSON index doesn't record its supplier name file, the current one is trusted if there is one
+	source, err := readIndexSource(supplierNameFilePath)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // stop the sort if the index can't be written
+	// the sorts and the index share the memory budget
+	budget := (opts.IndexMemoryBudget + 1) / 2
+	supplierChan, errChan := streamSuppliers(ctx, func(emit func(supplier *Supplier) error) error {
+		return sortJsonIndexed(indexedPath, legacy, budget, emit)
+	})
+	opts.IndexMemoryBudget = budget
+	return writeIndex(supplierNameFilePath, supplierChan, errChan, opts, source)
+}
+
+// streamSuppliers - send the suppliers emitted by send from a goroutine, to be written by writeIndex
+// emit returns the error of ctx once it is canceled, errChan receives the error returned by send.
+func streamSuppliers(ctx context.Context, send func(emit func(supplier *Supplier) error) error) (supplierChan chan *Supplier, errChan chan error) {
+	supplierChan = make(chan *Supplier, 100)
+	errChan = make(chan error, 1)
+	go func() {
+		defer close(errChan)
+		defer close(supplierChan)
+		errChan <- send(func(supplier *Supplier) error {
+			select {
+			case supplierChan <- supplier:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return supplierChan, errChan
+}
+
+// readIndexedFile - read the suppliers of the indexed file one by one in the order of the file, which is
+// the order of their line for the indexed files of the binary index
+func readIndexedFile(path string, read func(supplier *Supplier) error) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	reader := newSupplierReader(bufio.NewReader(f))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		supplier, err := parseIndexRecord(record)
+		if err != nil {
+			return err
+		}
+		if err = read(supplier); err != nil {
+			return err
+		}
+	}
+}
+
+// legacyIndexedRecord - a record of the indexed file of the first JSON index, the id and the raw name of a supplier
+var legacyIndexedRecord = regexp.MustCompile(`^(\d+),(.+)$`)
+
+// isLegacyIndexed - whether the indexed file is the one of the first JSON index, with "id,name" records written without quoting
+// the later ones are CSV files of "line,id,name[,alias[,identifiers]]" records
+func isLegacyIndexed(path string) (legacy bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	reader := newSupplierReader(bufio.NewReader(f))
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return false, nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) || err == nil && len(record) < 3 {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+}
+
+// jsonIndexedOrderSize - the size of the sort key of a record of the indexed file of a JSON index, its line and its number
+const jsonIndexedOrderSize = 40
+
+// sortJsonIndexed - emit the suppliers of the indexed file of a JSON index in the order of the supplier name file
+// The JSON index grouped the suppliers by key, so a supplier can be written in several groups. The records are sorted
+// with the run files of an indexBuilder within the memory budget, first by supplier to keep the first record of each,
+// then by line and in the order of the file. The "id,name" records of the first version don't have a line,
+// they are numbered in the order of the file after the header line of the supplier name file.
+func sortJsonIndexed(path string, legacy bool, budget int64, emit func(supplier *Supplier) error) (err error) {
+	unique := newIndexBuilder(path, budget)
+	defer unique.close()
+	err = readJsonIndexed(path, legacy, func(identity string, line, n int, text string) error {
+		sum := sha256.Sum256([]byte(identity))
+		key := fmt.Sprintf("%x%020d%020d%s", sum, line, n, text)
+		return unique.add(0, []string{key}, 0)
+	})
+	if err != nil {
+		return err
+	}
+
+	ordered := newIndexBuilder(path, budget)
+	defer ordered.close()
+	previous := ""
+	err = unique.merge(func(e indexEntry) error {
+		if identity := e.key[:sha256.Size*2]; identity != previous {
+			previous = identity
+			return ordered.add(0, []string{e.key[sha256.Size*2:]}, 0)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	unique.close()
+
+	line := 1
+	return ordered.merge(func(e indexEntry) error {
+		text := e.key[jsonIndexedOrderSize:]
+		var supplier *Supplier
+		if legacy {
+			line++
+			match := legacyIndexedRecord.FindStringSubmatch(text)
+			supplier = &Supplier{Id: match[1], SupplierName: match[2], Line: line}
+		} else {
+			record, err := newSupplierReader(strings.NewReader(text)).Read()
+			if err != nil {
+				return err
+			}
+			if supplier, err = parseIndexRecord(record); err != nil {
+				return err
+			}
+		}
+		return emit(supplier)
+	})
+}
+
+// readJsonIndexed - read the records of the indexed file of a JSON index one by one, with the identity of their supplier,
+// their line, their number in the file and their text
+func readJsonIndexed(path string, legacy bool, read func(identity string, line, n int, text string) error) (err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if legacy {
+		scanner := bufio.NewScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			match := legacyIndexedRecord.FindStringSubmatch(scanner.Text())
+			if match == nil {
+				return &SupplierFileError{File: path, Line: n, Text: scanner.Text()}
+			}
+			if err = read(match[1], 0, n, scanner.Text()); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+	reader := newSupplierReader(bufio.NewReader(f))
+	for n := 1; ; n++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		supplier, err := parseIndexRecord(record)
+		if err != nil {
+			return err
+		}
+		var text bytes.Buffer
+		if _, err = writeIndexRecord(&text, record); err != nil {
+			return err
+		}
+		if err = read(strings.Join(record[:3], ",")+","+supplier.Alias, supplier.Line, n, text.String()); err != nil {
+			return err
+		}
+	}
+}
+
+func newSupplierIndex() *supplierIndex {
+	return &supplierIndex{
+		Postings:    map[string][]uint64{},
+		TokenCounts: map[uint64]int{},
+	}
+}
+
+// add - add the supplier record at offset to the posting lists of its keys
+func (index *supplierIndex) add(offset uint64, keys []string, tokenCount int) {
+	for _, key := range keys {
+		index.Postings[key] = append(index.Postings[key], offset)
+	}
+	index.TokenCounts[offset] = tokenCount
+}
+
+// writeIndexRecord - write a CSV record of an index file, n is the number of bytes written
+func writeIndexRecord(w io.Writer, record []string) (n int, err error) {
+	var buf bytes.Buffer
+	cw := csv.NewWriter(&buf)
+	if err = cw.Write(record); err != nil {
+		return 0, err
+	}
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		return 0, err
+	}
+	return w.Write(buf.Bytes())
+}
+
+// writeIndex - write the index files of the suppliers of the source, see BuildIndex
+// errChan receives the error that stopped sending the suppliers if any, nothing is installed then.
+// The files are written to temporary files and installed together with their manifest once complete,
+// so an interrupted build leaves the previous index files in place, see installFiles.
+func writeIndex(supplierNameFilePath string, supplierChan <-chan *Supplier, errChan <-chan error, opts Options, source indexSource) (err error) {
+	f, err := createTempFile(fmt.Sprintf("%s.indexed", supplierNameFilePath))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.discard()
+		}
+	}()
+	builder := newIndexBuilder(fmt.Sprintf("%s.idx", supplierNameFilePath), opts.IndexMemoryBudget)
+	defer builder.close()
+	checksum := crc32.NewIEEE()
+	w := bufio.NewWriter(f)
+	indexed := io.MultiWriter(w, checksum)
+	currentIdx := uint64(0)
+	for supplier := range supplierChan {
+		keys := supplier.indexKeys(opts.Normalizer)
+		if len(keys) == 0 { // nothing left to match after normalization
+			continue
+		}
+		n, err := writeIndexRecord(indexed, supplier.indexRecord())
+		if err != nil {
+			return err
+		}
+		if err = builder.add(currentIdx, keys, supplier.indexTokenCount(opts.Normalizer, opts.OptionalSuffix)); err != nil {
+			return err
+		}
+		currentIdx += uint64(n)
+	}
+	if errChan != nil {
+		if err = <-errChan; err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	manifest := &indexManifest{indexed: fileChecksum{size: currentIdx, checksum: checksum.Sum32()}}
+
+	idxf, err := createTempFile(fmt.Sprintf("%s.idx", supplierNameFilePath))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			idxf.discard()
+		}
+	}()
+	if err = builder.writeTo(idxf, binaryIndexSource(source, manifest.indexed.size, manifest.indexed.checksum, indexOptionsHash(opts))); err != nil {
+		return err
+	}
+	header := make([]byte, indexHeaderSize)
+	if _, err = idxf.ReadAt(header, 0); err != nil {
+		return err
+	}
+	fi, err := idxf.Stat()
+	if err != nil {
+		return err
+	}
+	manifest.idx = fileChecksum{size: uint64(fi.Size()), checksum: binary.LittleEndian.Uint32(header[16:])}
+	// the suppliers of the segment are in the new index files
+	if manifest.segment, err = readSegmentChecksum(supplierNameFilePath); err != nil {
+		return err
+	}
+
+	if err = installFiles(f, idxf); err != nil {
+		return err
+	}
+	if err = writeIndexManifest(supplierNameFilePath, manifest); err != nil {
+		return err
+	}
+	if err = os.Remove(segmentPath(supplierNameFilePath)); os.IsNotExist(err) {
+		err = nil
+	}
+	return err
+}
+
+// indexFrequentKeyPostings - the number of suppliers above which a key, e.g. "company" or "ltd", is frequent,
+// see filterPotentialSuppliersForPage
+const indexFrequentKeyPostings = 4096
+
+// filterPotentialSuppliersForPage - read the potential suppliers of each page from the indexed file
+// a supplier is a potential supplier of a page if at least minTokenFraction of the distinct tokens of its name,
+// and at least one of them, are in the page, or if any of its identifiers is in the page.
+// The frequent keys count for the suppliers found by a rarer token of their name, a supplier found by frequent keys
+// only must have all its tokens in the page, otherwise every supplier named "... Company" would be read for a page
+// with "Company". Their posting lists are merged without counting every supplier in a map, see mergePostingLists.
+// The names are normalized with the normalizer of the page, which must be the one used to build the index
+func filterPotentialSuppliersForPage(ctx context.Context, pages []*Page, index *binaryIndex, indexed io.ReaderAt, minTokenFraction float64) (suppliersForPage []*SuppliersForPage, err error) {
+	suppliersForPage = make([]*SuppliersForPage, 0)
+	for _, page := range pages {
+		tokenHits := make(map[uint64]int)
+		tokenCounts := make(map[uint64]int)
+		identifierHits := make(map[uint64]bool)
+		visited := make(map[string]bool)
+		frequent := make([]postingList, 0)
+		for idxWord := range page.Words {
+			if err = ctx.Err(); err != nil {
+				return nil, err
+			}
+			for _, key := range page.indexLookupKeys(idxWord) {
+				if visited[key] {
+					continue
+				}
+				visited[key] = true
+				postings := index.lookup(key)
+				if postings.Len() > indexFrequentKeyPostings {
+					frequent = append(frequent, postings)
+					continue
+				}
+				for i := 0; i < postings.Len(); i++ {
+					p := postings.At(i)
+					tokenHits[p.offset]++
+					tokenCounts[p.offset] = p.tokenCount
+				}
+			}
+		}
+		// the suppliers found by the rarer keys are joined with the frequent lists by offset instead of looked up
+		found := make([]uint64, 0, len(tokenHits))
+		for offset := range tokenHits {
+			found = append(found, offset)
+		}
+		sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+		mergePostingLists(frequent, func(p posting, hits int) {
+			for len(found) > 0 && found[0] < p.offset {
+				found = found[1:]
+			}
+			if len(found) > 0 && found[0] == p.offset || hits >= p.tokenCount {
+				tokenHits[p.offset] += hits
+				tokenCounts[p.offset] = p.tokenCount
+			}
+		})
+		for _, key := range page.identifierKeys() {
+			postings := index.lookup(key)
+			for i := 0; i < postings.Len(); i++ {
+				identifierHits[postings.At(i).offset] = true
+			}
+		}
+
+		offsets := make([]uint64, 0)
+		for offset, hits := range tokenHits {
+			if hits >= minTokenHits(tokenCounts[offset], minTokenFraction) && !identifierHits[offset] {
+				offsets = append(offsets, offset)
+			}
+		}
+		for offset := range identifierHits {
+			offsets = append(offsets, offset)
+		}
+		// read the suppliers in the order of the supplier name file
+		sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+		suppliers := make([]*Supplier, 0, len(offsets))
+		for _, offset := range offsets {
+			supplier, err := readIndexedSupplier(indexed, offset)
+			if err != nil {
+				return nil, err
+			}
+			suppliers = append(suppliers, supplier)
+		}
+		if len(suppliers) > 0 {
+			suppliersForPage = append(suppliersForPage, &SuppliersForPage{
+				Page:      page,
+				Suppliers: suppliers,
+			})
+		}
+	}
+	return
+}
+
+// mergePostingLists - call emit with each supplier of the posting lists and the number of lists it is in, by offset
+func mergePostingLists(lists []postingList, emit func(p posting, hits int)) {
+	next := make([]int, len(lists))
+	heads := make([]posting, len(lists)) // the next posting of each list
+	for i, list := range lists {
+		if list.Len() > 0 {
+			heads[i] = list.At(0)
+		}
+	}
+	for {
+		var min posting
+		hits := 0
+		for i, list := range lists {
+			switch {
+			case next[i] == list.Len():
+			case hits == 0 || heads[i].offset < min.offset:
+				min, hits = heads[i], 1
+			case heads[i].offset == min.offset:
+				hits++
+			}
+		}
+		if hits == 0 {
+			return
+		}
+		for i, list := range lists {
+			if next[i] < list.Len() && heads[i].offset == min.offset {
+				if next[i]++; next[i] < list.Len() {
+					heads[i] = list.At(next[i])
+				}
+			}
+		}
+		emit(min, hits)
+	}
+}
+
+// minTokenHits - the min number of the tokens of a supplier name in a page to make it a potential supplier
+func minTokenHits(tokenCount int, minTokenFraction float64) int {
+	hits := int(math.Ceil(float64(tokenCount)*minTokenFraction - 1e-9))
+	if hits < 1 {
+		return 1
+	}
+	return hits
+}
+
+// indexKeys - the keys of the supplier in the index, the distinct tokens of its name or alias
+// and the keys of its identifiers, which only index the canonical supplier so that it is read once by identifier
+func (s *Supplier) indexKeys(normalizer Normalizer) (keys []string) {
+	seen := make(map[string]bool)
+	for _, token := range normalizer.Tokens(s.matchName()) {
+		if !seen[token] {
+			seen[token] = true
+			keys = append(keys, token)
+		}
+	}
+	if len(keys) > 0 && s.Alias == "" {
+		for _, id := range s.Identifiers {
+			keys = append(keys, id.key())
+		}
+	}
+	return
+}
+
+// indexTokenCount - the number of distinct tokens of the supplier name a page needs, see supplierIndex.TokenCounts
+func (s *Supplier) indexTokenCount(normalizer Normalizer, optSuffix bool) int {
+	tokens := normalizer.Tokens(s.matchName())
+	if optSuffix {
+		if trimmed := normalizer.Equivalents.trimSuffix(tokens); trimmed != nil {
+			tokens = trimmed
+		}
+	}
+	if trimmed := normalizer.trimArticle(tokens); trimmed != nil {
+		tokens = trimmed
+	}
+	distinct := make(map[string]bool)
+	for _, token := range tokens {
+		distinct[token] = true
+	}
+	return len(distinct)
+}
+
+// indexRecord - the CSV record of the supplier in the indexed file
+func (s *Supplier) indexRecord() (record []string) {
+	record = []string{strconv.Itoa(s.Line), s.Id, s.SupplierName}
+	if s.Alias != "" || len(s.Identifiers) > 0 {
+		record = append(record, s.Alias)
+	}
+	if len(s.Identifiers) > 0 {
+		ids := make([]string, len(s.Identifiers))
+		for i, id := range s.Identifiers {
+			ids[i] = id.String()
+		}
+		record = append(record, strings.Join(ids, aliasSeparator))
+	}
+	return
+}
+
+// readIndexedSupplier - read the supplier record at offset of the indexed file
+func readIndexedSupplier(indexed io.ReaderAt, offset uint64) (supplier *Supplier, err error) {
+	reader := newSupplierReader(io.NewSectionReader(indexed, int64(offset), math.MaxInt64-int64(offset)))
+	record, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	return parseIndexRecord(record)
+}
+
+// parseIndexRecord - the supplier of a record of the indexed file, see Supplier.indexRecord
+func parseIndexRecord(record []string) (supplier *Supplier, err error) {
+	if len(record) < 3 || len(record) > 5 {
+		return nil, fmt.Errorf("invalid supplier name text")
+	}
+	supplierLine, err := strconv.Atoi(record[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid supplier name text")
+	}
+	supplier = &Supplier{
+		Id:           record[1],
+		SupplierName: record[2],
+		Line:         supplierLine,
+	}
+	if len(record) > 3 {
+		supplier.Alias = record[3]
+	}
+	if len(record) > 4 {
+		for _, s := range strings.Split(record[4], aliasSeparator) {
+			id, err := parseIdentifier(s)
+			if err != nil {
+				return nil, err
+			}
+			supplier.Identifiers = append(supplier.Identifiers, id)
+		}
+	}
+	return supplier, nil
+}
+
+// isJsonIndex - whether the content of <supplier>.idx is a JSON index of the previous versions
+func isJsonIndex(data []byte) bool {
+	return len(bytes.TrimSpace(data)) > 0 && bytes.TrimSpace(data)[0] == '{'
+}
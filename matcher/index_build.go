@@ -0,0 +1,299 @@
+package matcher
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// indexEntrySize - the estimated memory of a buffered posting besides its key
+	indexEntrySize = 48
+	// indexMaxRuns - the max number of run files merged at once, more runs are merged into one first
+	indexMaxRuns = 64
+)
+
+// indexEntry - a posting of a key of the index being built
+type indexEntry struct {
+	key string
+	posting
+}
+
+// indexBuilder - the postings of the index files being built, within a memory budget
+// The postings are buffered until their estimated memory exceeds the budget, then sorted by key and spilled
+// to a temporary run file next to the index files. The runs are merged by key when the binary index is written,
+// so the index of tens of millions of suppliers is built without holding their postings in memory.
+type indexBuilder struct {
+	path    string // the path of the index file, the prefix of the run files
+	budget  int64  // the memory budget of the buffered postings, 0 means no limit
+	entries []indexEntry
+	size    int64 // the estimated memory of the entries
+	runs    []*os.File
+}
+
+func newIndexBuilder(path string, budget int64) *indexBuilder {
+	return &indexBuilder{path: path, budget: budget}
+}
+
+// add - add the supplier record at offset to the posting list of each key
+func (b *indexBuilder) add(offset uint64, keys []string, tokenCount int) (err error) {
+	for _, key := range keys {
+		b.entries = append(b.entries, indexEntry{key: key, posting: posting{offset: offset, tokenCount: tokenCount}})
+		b.size += int64(len(key)) + indexEntrySize
+	}
+	if b.budget > 0 && b.size > b.budget {
+		return b.spill()
+	}
+	return nil
+}
+
+// sortEntries - sort the buffered entries by key, the postings of a key stay in the order they were added
+func (b *indexBuilder) sortEntries() {
+	sort.SliceStable(b.entries, func(i, j int) bool { return b.entries[i].key < b.entries[j].key })
+}
+
+// spill - write the buffered entries sorted by key to a new run file
+func (b *indexBuilder) spill() (err error) {
+	b.sortEntries()
+	if err = b.writeRun(&entrySlice{entries: b.entries}); err != nil {
+		return err
+	}
+	b.entries = b.entries[:0]
+	b.size = 0
+	if len(b.runs) < indexMaxRuns {
+		return nil
+	}
+	// keep the number of open run files bounded by merging them into one
+	runs := b.runs
+	b.runs = nil
+	defer removeRuns(runs)
+	sources, err := runSources(runs)
+	if err != nil {
+		return err
+	}
+	return b.writeRun(sources...)
+}
+
+// writeRun - merge the entries of the sources into a new run file
+func (b *indexBuilder) writeRun(sources ...entrySource) (err error) {
+	f, err := ioutil.TempFile(filepath.Dir(b.path), filepath.Base(b.path)+".run-*")
+	if err != nil {
+		return err
+	}
+	b.runs = append(b.runs, f) // removed by close
+	w := bufio.NewWriter(f)
+	buf := make([]byte, binary.MaxVarintLen64)
+	writeUvarint := func(n uint64) (err error) {
+		_, err = w.Write(buf[:binary.PutUvarint(buf, n)])
+		return err
+	}
+	err = mergeEntries(sources, func(e indexEntry) (err error) {
+		if err = writeUvarint(uint64(len(e.key))); err != nil {
+			return err
+		}
+		if _, err = io.WriteString(w, e.key); err != nil {
+			return err
+		}
+		if err = writeUvarint(e.offset); err != nil {
+			return err
+		}
+		return writeUvarint(uint64(e.tokenCount))
+	})
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writeTo - write the binary index of the postings with its source section to f, see writeBinaryIndex
+// The sections are merged to temporary files first, since the header precedes them.
+func (b *indexBuilder) writeTo(f *tempFile, source []byte) (err error) {
+	files := make([]*os.File, 0, 3)
+	defer func() { removeRuns(files) }()
+	writers := make([]*bufio.Writer, 0, 3)
+	for i := 0; i < 3; i++ {
+		sf, err := ioutil.TempFile(filepath.Dir(b.path), filepath.Base(b.path)+".section-*")
+		if err != nil {
+			return err
+		}
+		files = append(files, sf)
+		writers = append(writers, bufio.NewWriter(sf))
+	}
+	sections := &indexSections{keys: writers[0], postings: writers[1], pool: writers[2]}
+	if err = b.merge(func(e indexEntry) error { return sections.add(e.key, e.posting) }); err != nil {
+		return err
+	}
+	if err = sections.endKey(); err != nil {
+		return err
+	}
+
+	// the header is written last with the checksum of the sections
+	checksum := crc32.NewIEEE()
+	w := bufio.NewWriter(f)
+	if _, err = w.Write(make([]byte, indexHeaderSize)); err != nil {
+		return err
+	}
+	if _, err = io.MultiWriter(w, checksum).Write(source); err != nil {
+		return err
+	}
+	for i, sf := range files {
+		if err = writers[i].Flush(); err != nil {
+			return err
+		}
+		if _, err = sf.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err = io.Copy(io.MultiWriter(w, checksum), sf); err != nil {
+			return err
+		}
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(sections.header(checksum.Sum32()), 0)
+	return err
+}
+
+// merge - emit the postings added to the builder in key order, the postings of a key in the order they were added
+func (b *indexBuilder) merge(emit func(e indexEntry) error) (err error) {
+	b.sortEntries()
+	sources, err := runSources(b.runs)
+	if err != nil {
+		return err
+	}
+	return mergeEntries(append(sources, &entrySlice{entries: b.entries}), emit)
+}
+
+// close - remove the run files and release the buffered postings
+func (b *indexBuilder) close() {
+	removeRuns(b.runs)
+	b.runs = nil
+	b.entries = nil
+}
+
+// removeRuns - close and remove the temporary files
+func removeRuns(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+		os.Remove(f.Name())
+	}
+}
+
+// entrySource - entries sorted by key, see mergeEntries
+type entrySource interface {
+	// next - the next entry, ok is false once there is none
+	next() (e indexEntry, ok bool, err error)
+}
+
+// entrySlice - the entries buffered in memory
+type entrySlice struct {
+	entries []indexEntry
+	i       int
+}
+
+func (s *entrySlice) next() (e indexEntry, ok bool, err error) {
+	if s.i == len(s.entries) {
+		return e, false, nil
+	}
+	s.i++
+	return s.entries[s.i-1], true, nil
+}
+
+// runReader - the entries of a run file, uvarint key length, key, uvarint offset and uvarint token count each
+type runReader struct {
+	r *bufio.Reader
+}
+
+// runSources - read the run files from the start
+func runSources(runs []*os.File) (sources []entrySource, err error) {
+	for _, f := range runs {
+		if _, err = f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		sources = append(sources, &runReader{r: bufio.NewReader(f)})
+	}
+	return sources, nil
+}
+
+func (r *runReader) next() (e indexEntry, ok bool, err error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return e, false, nil
+	}
+	if err != nil {
+		return e, false, err
+	}
+	key := make([]byte, n)
+	if _, err = io.ReadFull(r.r, key); err != nil {
+		return e, false, err
+	}
+	offset, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return e, false, err
+	}
+	tokenCount, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return e, false, err
+	}
+	return indexEntry{key: string(key), posting: posting{offset: offset, tokenCount: int(tokenCount)}}, true, nil
+}
+
+// mergeItem - the next entry of a source in the merge heap
+type mergeItem struct {
+	entry  indexEntry
+	source int
+}
+
+// mergeHeap - the next entries of the sources, by key then by source
+// The sources are in the order the suppliers were added, so the postings of a key stay by ascending offset.
+type mergeHeap []mergeItem
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	return h[i].entry.key < h[j].entry.key || h[i].entry.key == h[j].entry.key && h[i].source < h[j].source
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeItem)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// mergeEntries - emit the entries of the sorted sources in key order
+func mergeEntries(sources []entrySource, emit func(e indexEntry) error) (err error) {
+	h := make(mergeHeap, 0, len(sources))
+	for i, source := range sources {
+		e, ok, err := source.next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h = append(h, mergeItem{entry: e, source: i})
+		}
+	}
+	heap.Init(&h)
+	for h.Len() > 0 {
+		if err = emit(h[0].entry); err != nil {
+			return err
+		}
+		e, ok, err := sources[h[0].source].next()
+		if err != nil {
+			return err
+		}
+		if ok {
+			h[0].entry = e
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package matcher
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"sort"
+)
+
+// The binary format of <supplier>.idx, all integers are little endian:
+//
+//	header   magic "WSIX", version uint32, key count uint32, posting count uint32,
+//	         CRC-32 (IEEE) of everything after the header uint32
+//	source   the supplier name file the index is built from: size uint64, modification time in ns uint64,
+//	         SHA-256 [32]byte, then the size uint64 and the CRC-32 uint32 of <supplier>.indexed, see indexSource,
+//	         and the SHA-256 [32]byte of the options the keys are built with, see indexOptionsHash
+//	keys     a key entry per key sorted by key: pool offset uint32, key length uint32,
+//	         index of the first posting uint32, posting count uint32
+//	postings a posting per supplier of each key sorted by offset: supplier record offset uint64, token count uint32
+//	pool     the bytes of the keys
+//
+// The key entries and the postings have a fixed size, so a key is binary searched in place without decoding the file.
+const (
+	indexMagic        = "WSIX"
+	indexVersion      = uint32(3)
+	indexHeaderSize   = 20
+	indexSourceSize   = 92
+	indexKeyEntrySize = 16
+	indexPostingSize  = 12
+)
+
+// posting - a supplier in the posting list of a key of the index
+type posting struct {
+	offset     uint64 // the offset of the supplier record in <supplier>.indexed
+	tokenCount int    // the number of distinct tokens of the supplier name, see supplierIndex.TokenCounts
+}
+
+// binaryIndex - the index read from its binary format, see writeBinaryIndex
+type binaryIndex struct {
+	checksum        uint32 // the checksum of the header
+	source          indexSource
+	indexedSize     uint64
+	indexedChecksum uint32
+	options         [sha256.Size]byte
+
+	keyCount int
+	keys     []byte // the key entries
+	postings []byte
+	pool     []byte
+}
+
+// writeBinaryIndex - write the index in its binary format
+func writeBinaryIndex(w io.Writer, index *supplierIndex) (err error) {
+	keys := make([]string, 0, len(index.Postings))
+	for key := range index.Postings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var keyTable, postings, pool bytes.Buffer
+	sections := &indexSections{keys: &keyTable, postings: &postings, pool: &pool}
+	for _, key := range keys {
+		for _, offset := range index.Postings[key] {
+			if err = sections.add(key, posting{offset: offset, tokenCount: index.TokenCounts[offset]}); err != nil {
+				return err
+			}
+		}
+	}
+	if err = sections.endKey(); err != nil {
+		return err
+	}
+
+	source := binaryIndexSource(index.Source, index.IndexedSize, index.IndexedChecksum, index.Options)
+	checksum := crc32.NewIEEE()
+	checksum.Write(source)
+	checksum.Write(keyTable.Bytes())
+	checksum.Write(postings.Bytes())
+	checksum.Write(pool.Bytes())
+	for _, b := range [][]byte{sections.header(checksum.Sum32()), source, keyTable.Bytes(), postings.Bytes(), pool.Bytes()} {
+		if _, err = w.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// binaryIndexSource - the source section of the binary index
+func binaryIndexSource(source indexSource, indexedSize uint64, indexedChecksum uint32, options [sha256.Size]byte) []byte {
+	b := make([]byte, indexSourceSize)
+	binary.LittleEndian.PutUint64(b[0:], uint64(source.size))
+	binary.LittleEndian.PutUint64(b[8:], uint64(source.modTime))
+	copy(b[16:48], source.hash[:])
+	binary.LittleEndian.PutUint64(b[48:], indexedSize)
+	binary.LittleEndian.PutUint32(b[56:], indexedChecksum)
+	copy(b[60:92], options[:])
+	return b
+}
+
+// indexSections - the key table, the postings and the pool of a binary index written posting by posting,
+// the keys must be added in order and the postings of a key by ascending offset
+type indexSections struct {
+	keys, postings, pool io.Writer
+
+	keyCount, postingCount, poolSize uint64
+	key                              string // the key of the postings added since the last key entry
+	first                            uint64 // the index of the first posting of key
+	open                             bool   // whether postings were added since the last key entry
+	buf                              [indexKeyEntrySize]byte
+}
+
+// add - add the posting to the posting list of the key
+func (s *indexSections) add(key string, p posting) (err error) {
+	if s.open && key != s.key {
+		if err = s.endKey(); err != nil {
+			return err
+		}
+	}
+	if !s.open {
+		s.key, s.first, s.open = key, s.postingCount, true
+	}
+	if s.postingCount+1 > math.MaxUint32 {
+		return fmt.Errorf("index too large")
+	}
+	b := s.buf[:indexPostingSize]
+	binary.LittleEndian.PutUint64(b[0:], p.offset)
+	binary.LittleEndian.PutUint32(b[8:], uint32(p.tokenCount))
+	if _, err = s.postings.Write(b); err != nil {
+		return err
+	}
+	s.postingCount++
+	return nil
+}
+
+// endKey - write the key entry of the postings added since the last one
+func (s *indexSections) endKey() (err error) {
+	if !s.open {
+		return nil
+	}
+	s.open = false
+	if s.poolSize+uint64(len(s.key)) > math.MaxUint32 || s.keyCount+1 > math.MaxUint32 {
+		return fmt.Errorf("index too large")
+	}
+	b := s.buf[:indexKeyEntrySize]
+	binary.LittleEndian.PutUint32(b[0:], uint32(s.poolSize))
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(s.key)))
+	binary.LittleEndian.PutUint32(b[8:], uint32(s.first))
+	binary.LittleEndian.PutUint32(b[12:], uint32(s.postingCount-s.first))
+	if _, err = s.keys.Write(b); err != nil {
+		return err
+	}
+	if _, err = io.WriteString(s.pool, s.key); err != nil {
+		return err
+	}
+	s.poolSize += uint64(len(s.key))
+	s.keyCount++
+	return nil
+}
+
+// header - the header of the binary index of the sections with the checksum of everything after it
+func (s *indexSections) header(checksum uint32) []byte {
+	header := make([]byte, indexHeaderSize)
+	copy(header, indexMagic)
+	binary.LittleEndian.PutUint32(header[4:], indexVersion)
+	binary.LittleEndian.PutUint32(header[8:], uint32(s.keyCount))
+	binary.LittleEndian.PutUint32(header[12:], uint32(s.postingCount))
+	binary.LittleEndian.PutUint32(header[16:], checksum)
+	return header
+}
+
+// parseBinaryIndex - check the header and the size of the sections of the index in its binary format,
+// the data is not copied. The checksum of the content is checked by verify, which reads it entirely.
+func parseBinaryIndex(data []byte) (index *binaryIndex, err error) {
+	if len(data) < indexHeaderSize || string(data[:4]) != indexMagic {
+		return nil, fmt.Errorf("invalid index: bad magic")
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != indexVersion {
+		return nil, fmt.Errorf("%w: index version %d, want %d", ErrStaleIndex, version, indexVersion)
+	}
+	if len(data) < indexHeaderSize+indexSourceSize {
+		return nil, fmt.Errorf("invalid index: truncated")
+	}
+	keyCount := uint64(binary.LittleEndian.Uint32(data[8:]))
+	postingCount := uint64(binary.LittleEndian.Uint32(data[12:]))
+	postingsStart := indexHeaderSize + indexSourceSize + keyCount*indexKeyEntrySize
+	poolStart := postingsStart + postingCount*indexPostingSize
+	if poolStart > uint64(len(data)) {
+		return nil, fmt.Errorf("invalid index: truncated")
+	}
+	source := data[indexHeaderSize:]
+	index = &binaryIndex{
+		checksum: binary.LittleEndian.Uint32(data[16:]),
+		source: indexSource{
+			size:    int64(binary.LittleEndian.Uint64(source[0:])),
+			modTime: int64(binary.LittleEndian.Uint64(source[8:])),
+		},
+		indexedSize:     binary.LittleEndian.Uint64(source[48:]),
+		indexedChecksum: binary.LittleEndian.Uint32(source[56:]),
+
+		keyCount: int(keyCount),
+		keys:     data[indexHeaderSize+indexSourceSize : postingsStart],
+		postings: data[postingsStart:poolStart],
+		pool:     data[poolStart:],
+	}
+	copy(index.source.hash[:], source[16:48])
+	copy(index.options[:], source[60:92])
+	return index, nil
+}
+
+// verify - check the checksum of the content of the binary index parsed from data
+func (index *binaryIndex) verify(data []byte) error {
+	if crc32.ChecksumIEEE(data[indexHeaderSize:]) != index.checksum {
+		return fmt.Errorf("invalid index: checksum mismatch")
+	}
+	return nil
+}
+
+// key - the key of the i-th key entry
+func (index *binaryIndex) key(i int) []byte {
+	entry := index.keys[i*indexKeyEntrySize:]
+	start := uint64(binary.LittleEndian.Uint32(entry[0:]))
+	end := start + uint64(binary.LittleEndian.Uint32(entry[4:]))
+	if end > uint64(len(index.pool)) {
+		return nil
+	}
+	return index.pool[start:end]
+}
+
+// postingList - a posting list read in place from the binary index
+type postingList []byte
+
+// Len - the number of suppliers of the posting list
+func (l postingList) Len() int {
+	return len(l) / indexPostingSize
+}
+
+// At - the i-th posting of the posting list
+func (l postingList) At(i int) posting {
+	p := l[i*indexPostingSize:]
+	return posting{
+		offset:     binary.LittleEndian.Uint64(p[0:]),
+		tokenCount: int(binary.LittleEndian.Uint32(p[8:])),
+	}
+}
+
+// lookup - the posting list of the key, empty if the key is not in the index
+func (index *binaryIndex) lookup(key string) postingList {
+	k := []byte(key)
+	i := sort.Search(index.keyCount, func(i int) bool { return bytes.Compare(index.key(i), k) >= 0 })
+	if i == index.keyCount || !bytes.Equal(index.key(i), k) {
+		return nil
+	}
+	entry := index.keys[i*indexKeyEntrySize:]
+	first := uint64(binary.LittleEndian.Uint32(entry[8:]))
+	count := uint64(binary.LittleEndian.Uint32(entry[12:]))
+	if (first+count)*indexPostingSize > uint64(len(index.postings)) {
+		return nil
+	}
+	return postingList(index.postings[first*indexPostingSize : (first+count)*indexPostingSize])
+}
@@ -0,0 +1,209 @@
+package matcher
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+const (
+	// manifestIdx, manifestIndexed, manifestSegment - the files of the records of the manifest
+	manifestIdx     = "idx"
+	manifestIndexed = "indexed"
+	manifestSegment = "segment"
+)
+
+// manifestPath - the path of the manifest of the index files of the supplier name file
+func manifestPath(supplierNameFilePath string) string {
+	return fmt.Sprintf("%s.manifest", supplierNameFilePath)
+}
+
+// fileChecksum - the size and the CRC-32 (IEEE) of the content of a file
+type fileChecksum struct {
+	size     uint64
+	checksum uint32
+}
+
+// indexManifest - the index files installed together by writeIndex
+// <supplier>.manifest is written once both index files are renamed to their path, a CSV record "file,size,checksum" per file:
+// "idx" with the checksum of its header, "indexed", and "segment" the prefix of the segment merged into the index files,
+// which is skipped if the segment could not be removed. A manifest that doesn't match the index files reveals
+// an interrupted install, the index files without manifest are trusted, e.g. the ones of a previous version.
+type indexManifest struct {
+	idx     fileChecksum
+	indexed fileChecksum
+	segment fileChecksum
+}
+
+// readIndexManifest - read the manifest of the index files of the supplier name file, nil if there is none
+func readIndexManifest(supplierNameFilePath string) (manifest *indexManifest, err error) {
+	path := manifestPath(supplierNameFilePath)
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	records, err := newSupplierReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	manifest = &indexManifest{}
+	for _, record := range records {
+		if len(record) != 3 {
+			return nil, fmt.Errorf("%s: invalid manifest record", path)
+		}
+		size, err1 := strconv.ParseUint(record[1], 10, 64)
+		checksum, err2 := strconv.ParseUint(record[2], 10, 32)
+		if err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("%s: invalid manifest record", path)
+		}
+		file := fileChecksum{size: size, checksum: uint32(checksum)}
+		switch record[0] {
+		case manifestIdx:
+			manifest.idx = file
+		case manifestIndexed:
+			manifest.indexed = file
+		case manifestSegment:
+			manifest.segment = file
+		default:
+			return nil, fmt.Errorf("%s: invalid manifest record", path)
+		}
+	}
+	return manifest, nil
+}
+
+// matches - whether the manifest is the one of the binary index of size bytes
+func (manifest *indexManifest) matches(index *binaryIndex, size int) bool {
+	return manifest.idx == fileChecksum{size: uint64(size), checksum: index.checksum} &&
+		manifest.indexed == fileChecksum{size: index.indexedSize, checksum: index.indexedChecksum}
+}
+
+// writeIndexManifest - replace the manifest of the index files of the supplier name file
+func writeIndexManifest(supplierNameFilePath string, manifest *indexManifest) (err error) {
+	var buf bytes.Buffer
+	for _, r := range []struct {
+		file string
+		fileChecksum
+	}{{manifestIdx, manifest.idx}, {manifestIndexed, manifest.indexed}, {manifestSegment, manifest.segment}} {
+		if _, err = writeIndexRecord(&buf, []string{r.file, strconv.FormatUint(r.size, 10), strconv.FormatUint(uint64(r.checksum), 10)}); err != nil {
+			return err
+		}
+	}
+	path := manifestPath(supplierNameFilePath)
+	f, err := createTempFile(path)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(buf.Bytes()); err != nil {
+		f.discard()
+		return err
+	}
+	return installFiles(f)
+}
+
+// readSegmentChecksum - the size and the checksum of the segment of the supplier name file, zero if there is none
+func readSegmentChecksum(supplierNameFilePath string) (segment fileChecksum, err error) {
+	data, err := ioutil.ReadFile(segmentPath(supplierNameFilePath))
+	if os.IsNotExist(err) {
+		return fileChecksum{}, nil
+	}
+	if err != nil {
+		return fileChecksum{}, err
+	}
+	return fileChecksum{size: uint64(len(data)), checksum: crc32.ChecksumIEEE(data)}, nil
+}
+
+// skipMergedSegment - skip the prefix of the segment file already merged into the index files by the manifest
+// the prefix is left if the segment could not be removed once the index files were installed
+func skipMergedSegment(f *os.File, manifest *indexManifest) (err error) {
+	if manifest == nil || manifest.segment.size == 0 {
+		return nil
+	}
+	checksum := crc32.NewIEEE()
+	n, err := io.CopyN(checksum, f, int64(manifest.segment.size))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if uint64(n) == manifest.segment.size && checksum.Sum32() == manifest.segment.checksum {
+		return nil
+	}
+	_, err = f.Seek(0, io.SeekStart) // not the merged segment, it is replayed from the start
+	return err
+}
+
+// tempFile - a temporary file written next to its path, see installFiles
+type tempFile struct {
+	*os.File
+	path string
+}
+
+// createTempFile - create a temporary file next to path, to be installed at path by installFiles
+func createTempFile(path string) (f *tempFile, err error) {
+	file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	// the mode of the files written before, instead of the private mode of a temporary file
+	if err = file.Chmod(0644); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &tempFile{File: file, path: path}, nil
+}
+
+// discard - close and remove the temporary file, which is not installed
+func (f *tempFile) discard() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// installFiles - flush the temporary files to disk and rename them to their path in order
+// The files are discarded if any of them can't be flushed, so the previous files are left in place.
+// A crash between two renames leaves a new file next to a previous one, readers detect it with the manifest.
+func installFiles(files ...*tempFile) (err error) {
+	for _, f := range files {
+		if err == nil {
+			err = f.Sync()
+		}
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		for _, f := range files {
+			os.Remove(f.Name())
+		}
+		return err
+	}
+	for _, f := range files {
+		if err = os.Rename(f.Name(), f.path); err != nil {
+			return err
+		}
+		if err = syncDir(filepath.Dir(f.path)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncDir - flush the entries of the directory to disk so the files renamed in it survive a crash
+func syncDir(dir string) (err error) {
+	if runtime.GOOS == "windows" { // a directory can't be opened to be synced
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,198 @@
+package matcher
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync"
+)
+
+// Index - the index files of a supplier name file mapped in memory, see BuildIndex
+// an Index is opened once and searched by many invoices, concurrently if needed, without reading the files again.
+// It must be closed before the index files are built again, Close waits for the searches in progress.
+type Index struct {
+	mu      sync.RWMutex // held for reading by the searches reading the mapped files, for writing by Close
+	index   *binaryIndex
+	indexed *bytes.Reader // the records of <supplier>.indexed
+	unmaps  []func() error
+
+	// the suppliers updated since the index files were built, nil without segment, see UpdateIndex
+	segment        *binaryIndex
+	segmentIndexed *bytes.Reader
+	deleted        map[string]bool
+}
+
+// ErrIndexClosed - the index is searched after it was closed
+var ErrIndexClosed = errors.New("index closed")
+
+// OpenIndex - map the index files of the supplier name file in memory and read their segment
+// return an error wrapping ErrStaleIndex if the index files were built by another version, don't match each other,
+// were built with other options than opts, or the supplier name file changed since they were built or updated,
+// see Options.RebuildStaleIndex. The index must be searched with opts.
+func OpenIndex(supplierNameFilePath string, opts Options) (index *Index, err error) {
+	opened := &Index{}
+	defer func() {
+		if err != nil { // unmap the files mapped before the error
+			opened.Close()
+		}
+	}()
+
+	idxPath := fmt.Sprintf("%s.idx", supplierNameFilePath)
+	idx, unmap, err := mapFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	opened.unmaps = append(opened.unmaps, unmap)
+	if opened.index, err = parseIndexFile(idxPath, idx, opts); err != nil {
+		return nil, err
+	}
+
+	indexedPath := fmt.Sprintf("%s.indexed", supplierNameFilePath)
+	indexed, unmap, err := mapFile(indexedPath)
+	if err != nil {
+		return nil, err
+	}
+	opened.unmaps = append(opened.unmaps, unmap)
+	matching := uint64(len(indexed)) == opened.index.indexedSize
+	if matching {
+		matching, err = verifyOnce(indexedPath, fmt.Sprintf("%08x", opened.index.indexedChecksum), func() (bool, error) {
+			return crc32.ChecksumIEEE(indexed) == opened.index.indexedChecksum, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !matching {
+		return nil, fmt.Errorf("%s: %w: the index files don't match each other", idxPath, ErrStaleIndex)
+	}
+	manifest, err := readIndexManifest(supplierNameFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if manifest != nil && !manifest.matches(opened.index, len(idx)) {
+		return nil, fmt.Errorf("%s: %w: the index files are partially installed", idxPath, ErrStaleIndex)
+	}
+	opened.indexed = bytes.NewReader(indexed)
+
+	segment, err := readIndexSegment(supplierNameFilePath)
+	if err != nil {
+		return nil, err
+	}
+	source := opened.index.source
+	if segment.source.known() { // the supplier name file was edited like the last delta
+		source = segment.source
+	}
+	fresh, err := source.matches(supplierNameFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, fmt.Errorf("%s: %w: %s changed since the index was built", idxPath, ErrStaleIndex, supplierNameFilePath)
+	}
+	if len(segment.ids) > 0 || len(segment.deleted) > 0 {
+		if opened.segment, opened.segmentIndexed, err = segment.index(); err != nil {
+			return nil, err
+		}
+		opened.deleted = segment.deleted
+	}
+	return opened, nil
+}
+
+// parseIndexFile - parse the content of the <supplier>.idx file at idxPath, which must be built with opts
+// its checksum is checked once per version of the file, see verifyOnce
+func parseIndexFile(idxPath string, data []byte, opts Options) (index *binaryIndex, err error) {
+	if isJsonIndex(data) {
+		return nil, fmt.Errorf("%s: %w: JSON index of a previous version, convert it with ConvertIndex or build it again", idxPath, ErrStaleIndex)
+	}
+	if index, err = parseBinaryIndex(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", idxPath, err)
+	}
+	if _, err = verifyOnce(idxPath, fmt.Sprintf("%08x", index.checksum), func() (bool, error) {
+		return true, index.verify(data)
+	}); err != nil {
+		return nil, fmt.Errorf("%s: %w", idxPath, err)
+	}
+	if index.options != indexOptionsHash(opts) {
+		return nil, fmt.Errorf("%s: %w: the index was built with other normalizer, equivalents, optional suffix or identifiers options", idxPath, ErrStaleIndex)
+	}
+	return index, nil
+}
+
+// Close - unmap the index files, the index can't be searched anymore
+// the searches in progress complete first, the searches after Close return ErrIndexClosed
+func (index *Index) Close() (err error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	for _, unmap := range index.unmaps {
+		if e := unmap(); e != nil && err == nil {
+			err = e
+		}
+	}
+	index.unmaps = nil
+	index.index, index.indexed = nil, nil
+	index.segment, index.segmentIndexed, index.deleted = nil, nil, nil
+	return
+}
+
+// FindSupplierName - find the supplier name of the invoice with the index, see FindSupplierNameV2
+func (index *Index) FindSupplierName(ctx context.Context, invoiceFilePath string, opts Options) (result *MatchResult, err error) {
+	potentialSuppliersForPage, err := index.findPotentialSuppliers(ctx, invoiceFilePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return firstMatchResult(SearchSuppliersFromPageV3(potentialSuppliersForPage)), nil
+}
+
+// FindSupplierNames - find all the supplier names matching the invoice with the index, see FindSupplierNamesV2
+func (index *Index) FindSupplierNames(ctx context.Context, invoiceFilePath string, opts Options) (results []*MatchResult, err error) {
+	potentialSuppliersForPage, err := index.findPotentialSuppliers(ctx, invoiceFilePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return rankMatchResults(SearchSuppliersFromPageV3(potentialSuppliersForPage), opts.TopK), nil
+}
+
+// findPotentialSuppliers - load the invoice and read the potential suppliers of each page from the index
+func (index *Index) findPotentialSuppliers(ctx context.Context, invoiceFilePath string, opts Options) (potentialSuppliersForPage []*SuppliersForPage, err error) {
+	if err = opts.validate(); err != nil {
+		return nil, err
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// preprocess the invoice file
+	words, err := LoadInvoice(invoiceFilePath, opts.InvoiceFormat)
+	if err != nil {
+		return nil, err
+	}
+	pages := NewPages(words, opts)
+
+	// the mapped files are read until the suppliers are parsed, Close waits until then
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	if index.index == nil {
+		return nil, ErrIndexClosed
+	}
+	if indexOptionsHash(opts) != index.index.options {
+		return nil, fmt.Errorf("%w: the index is searched with other options than the ones it was opened with", ErrStaleIndex)
+	}
+
+	potentialSuppliersForPage, err = filterPotentialSuppliersForPage(ctx, pages, index.index, index.indexed, opts.MinTokenFraction)
+	if err != nil {
+		return nil, err
+	}
+	if index.segment != nil {
+		segmentSuppliers, err := filterPotentialSuppliersForPage(ctx, pages, index.segment, index.segmentIndexed, opts.MinTokenFraction)
+		if err != nil {
+			return nil, err
+		}
+		potentialSuppliersForPage = mergeSegmentSuppliers(pages, potentialSuppliersForPage, segmentSuppliers, index.deleted)
+	}
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	return potentialSuppliersForPage, nil
+}
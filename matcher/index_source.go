@@ -0,0 +1,143 @@
+package matcher
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrStaleIndex - the index files don't match the supplier name file or this version, they must be built again
+var ErrStaleIndex = errors.New("stale index")
+
+// indexSource - the supplier name file an index is built from, the zero value is an unknown file
+type indexSource struct {
+	size    int64
+	modTime int64 // the modification time in ns since the Unix epoch
+	hash    [sha256.Size]byte
+}
+
+// readIndexSource - the size, the modification time and the hash of the supplier name file,
+// the zero value if the file does not exist
+func readIndexSource(supplierNameFilePath string) (source indexSource, err error) {
+	f, err := os.Open(supplierNameFilePath)
+	if os.IsNotExist(err) {
+		return indexSource{}, nil
+	}
+	if err != nil {
+		return indexSource{}, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return indexSource{}, err
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return indexSource{}, err
+	}
+	source.size = fi.Size()
+	source.modTime = fi.ModTime().UnixNano()
+	copy(source.hash[:], h.Sum(nil))
+	return source, nil
+}
+
+// known - whether the supplier name file of the index is known, it is not for an index converted without it
+func (source indexSource) known() bool {
+	return source != indexSource{}
+}
+
+// matches - whether the supplier name file is the one the index is built from
+// The file is hashed only if its modification time changed, so a copy of the same content still matches,
+// and only once per version of the file, see verifyOnce.
+// An unknown source or a missing supplier name file, e.g. when only the index files are deployed, always matches.
+func (source indexSource) matches(supplierNameFilePath string) (bool, error) {
+	if !source.known() {
+		return true, nil
+	}
+	fi, err := os.Stat(supplierNameFilePath)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if fi.Size() != source.size {
+		return false, nil
+	}
+	if fi.ModTime().UnixNano() == source.modTime {
+		return true, nil
+	}
+	return verifyOnce(supplierNameFilePath, fmt.Sprintf("%x", source.hash), func() (bool, error) {
+		current, err := readIndexSource(supplierNameFilePath)
+		if err != nil {
+			return false, err
+		}
+		return current.hash == source.hash, nil
+	})
+}
+
+// indexOptionsHash - the fingerprint of the options changing the keys of the index, which must be searched with the
+// options it was built with: the normalizer and its equivalent tokens, Options.OptionalSuffix and Options.MatchIdentifiers
+func indexOptionsHash(opts Options) (hash [sha256.Size]byte) {
+	n := opts.Normalizer
+	var b strings.Builder
+	fmt.Fprintf(&b, "fold=%t,nfkc=%t,trim=%t,split=%t,article=%t,suffix=%t,identifiers=%t\n",
+		n.FoldCase, n.NFKC, n.TrimPunct, n.SplitPunct, n.OptionalArticle, opts.OptionalSuffix, opts.MatchIdentifiers)
+	if e := n.Equivalents; e != nil {
+		for _, m := range []map[string]string{e.canonical, e.symbols} {
+			for _, token := range sortedKeys(m) {
+				fmt.Fprintf(&b, "%q=%q,suffix=%t\n", token, m[token], e.suffix[m[token]])
+			}
+			b.WriteString("\n")
+		}
+	}
+	return sha256.Sum256([]byte(b.String()))
+}
+
+// sortedKeys - the keys of the map in order
+func sortedKeys(m map[string]string) (keys []string) {
+	keys = make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// verifiedFiles - the versions of the files whose content was checked by the process, see verifyOnce
+var verifiedFiles sync.Map
+
+// fileVersion - a version of a file by its size and modification time, with the checksum its content must have
+type fileVersion struct {
+	path     string
+	size     int64
+	modTime  int64
+	checksum string
+}
+
+// verifyOnce - check the content of the file with verify, unless this version of the file was already checked
+// against the same checksum, so an index opened many times is only read entirely once. Only successes are cached.
+func verifyOnce(path string, checksum string, verify func() (bool, error)) (ok bool, err error) {
+	if path, err = filepath.Abs(path); err != nil {
+		return false, err
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	version := fileVersion{path: path, size: fi.Size(), modTime: fi.ModTime().UnixNano(), checksum: checksum}
+	if _, found := verifiedFiles.Load(version); found {
+		return true, nil
+	}
+	if ok, err = verify(); err != nil || !ok {
+		return ok, err
+	}
+	verifiedFiles.Store(version, true)
+	return true, nil
+}
@@ -0,0 +1,790 @@
+package matcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_minTokenHits(t *testing.T) {
+	tests := []struct {
+		tokenCount int
+		fraction   float64
+		want       int
+	}{
+		{tokenCount: 3, fraction: 0, want: 1},
+		{tokenCount: 3, fraction: 0.5, want: 2},
+		{tokenCount: 2, fraction: 0.5, want: 1},
+		{tokenCount: 3, fraction: 1, want: 3},
+		{tokenCount: 10, fraction: 0.3, want: 3},
+	}
+	for _, tt := range tests {
+		if got := minTokenHits(tt.tokenCount, tt.fraction); got != tt.want {
+			t.Errorf("minTokenHits(%d, %v) = %d, want %d", tt.tokenCount, tt.fraction, got, tt.want)
+		}
+	}
+}
+
+func TestFindSupplierNameV2_allTokens(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName\n1,Another Company\n2,Demo Company\n3,The Fine Foods Company\n")
+	fuzzy := DefaultOptions()
+	fuzzy.MaxDistance = 1
+	allTokens := fuzzy
+	allTokens.MinTokenFraction = 1
+	exact := DefaultOptions()
+	exact.Normalizer = Normalizer{}
+	tests := []struct {
+		name    string
+		invoice string
+		opts    Options
+		want    string
+	}{
+		{name: "garbled first word", invoice: "INVOICE\nDem0 Company\n", opts: fuzzy, want: "2"},
+		{name: "garbled first word with all tokens", invoice: "INVOICE\nDem0 Company\n", opts: allTokens, want: ""},
+		{name: "without leading article", invoice: "INVOICE\nFine Foods Company\n", opts: DefaultOptions(), want: "3"},
+		{name: "without leading article with all tokens", invoice: "INVOICE\nFine Foods Company\n", opts: allTokens, want: "3"},
+		{name: "without leading article exact", invoice: "INVOICE\nFine Foods Company\n", opts: exact, want: ""},
+		{name: "not enough tokens", invoice: "INVOICE\nFoods Company\n", opts: DefaultOptions(), want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoiceFilePath := writeInvoiceFile(t, tt.invoice)
+			if _, err := BuildIndex(supplierNameFilePath, tt.opts); err != nil {
+				t.Fatalf("BuildIndex() error = %v", err)
+			}
+			got, _, err := FindSupplierNameV2(context.Background(), invoiceFilePath, supplierNameFilePath, tt.opts)
+			if err != nil {
+				t.Fatalf("FindSupplierNameV2() error = %v", err)
+			}
+			gotId := ""
+			if got != nil {
+				gotId = got.SupplierId
+			}
+			if gotId != tt.want {
+				t.Errorf("FindSupplierNameV2() = %v, want supplier %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeBinaryIndex(t *testing.T) {
+	index := &supplierIndex{
+		Postings:    map[string][]uint64{"demo": {0, 42}, "company": {0}, "tax 51824753556": {42}},
+		TokenCounts: map[uint64]int{0: 2, 42: 1},
+	}
+	var buf bytes.Buffer
+	if err := writeBinaryIndex(&buf, index); err != nil {
+		t.Fatalf("writeBinaryIndex() error = %v", err)
+	}
+	data := buf.Bytes()
+	got, err := parseBinaryIndex(data)
+	if err != nil {
+		t.Fatalf("parseBinaryIndex() error = %v", err)
+	}
+	tests := []struct {
+		key  string
+		want []posting
+	}{
+		{key: "demo", want: []posting{{offset: 0, tokenCount: 2}, {offset: 42, tokenCount: 1}}},
+		{key: "company", want: []posting{{offset: 0, tokenCount: 2}}},
+		{key: "tax 51824753556", want: []posting{{offset: 42, tokenCount: 1}}},
+		{key: "dem", want: nil},
+		{key: "zzz", want: nil},
+	}
+	for _, tt := range tests {
+		var postings []posting
+		list := got.lookup(tt.key)
+		for i := 0; i < list.Len(); i++ {
+			postings = append(postings, list.At(i))
+		}
+		if !reflect.DeepEqual(postings, tt.want) {
+			t.Errorf("lookup(%q) = %v, want %v", tt.key, postings, tt.want)
+		}
+	}
+
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	version := append([]byte{}, data...)
+	version[4] = 9
+	for name, data := range map[string][]byte{"version": version, "truncated": data[:30], "magic": []byte(`{"demo":0}`)} {
+		if _, err := parseBinaryIndex(data); err == nil {
+			t.Errorf("parseBinaryIndex(%s) error = nil, want error", name)
+		}
+	}
+	if err := got.verify(data); err != nil {
+		t.Errorf("verify() error = %v", err)
+	}
+	if index, err := parseBinaryIndex(corrupted); err != nil || index.verify(corrupted) == nil {
+		t.Errorf("verify(checksum) error = nil, want error")
+	}
+}
+
+func TestConvertIndex(t *testing.T) {
+	// the index files of the previous versions grouping the suppliers by the first token of their name
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName,Aliases\n1,Another Company,\n2,Demo Company,Demo Co\n")
+	indexed := "2,1,Another Company\n3,2,Demo Company\n3,2,Demo Company,Demo Co\n"
+	if err := os.WriteFile(supplierNameFilePath+".indexed", []byte(indexed), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(supplierNameFilePath+".idx", []byte(`{"another":0,"demo":20}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	invoiceFilePath := writeInvoiceFile(t, "INVOICE\nDemo Co\n")
+	opts := DefaultOptions()
+	if _, _, err := FindSupplierNameV2(context.Background(), invoiceFilePath, supplierNameFilePath, opts); err == nil {
+		t.Fatalf("FindSupplierNameV2() error = nil, want a JSON index error")
+	}
+	if err := ConvertIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("ConvertIndex() error = %v", err)
+	}
+	got, _, err := FindSupplierNameV2(context.Background(), invoiceFilePath, supplierNameFilePath, opts)
+	if err != nil {
+		t.Fatalf("FindSupplierNameV2() error = %v", err)
+	}
+	want := &Supplier{Id: "2", SupplierName: "Demo Company", Line: 3, Alias: "Demo Co"}
+	if !reflect.DeepEqual(got.Supplier(), want) {
+		t.Errorf("FindSupplierNameV2() = %+v, want %+v", got.Supplier(), want)
+	}
+	if err := ConvertIndex(supplierNameFilePath, opts); err == nil {
+		t.Errorf("ConvertIndex() error = nil, want not a JSON index")
+	}
+}
+
+func TestConvertIndex_firstVersion(t *testing.T) {
+	// the index files of the first version, "id,name" records grouped by the first word of the name
+	supplierNameFilePath := copySupplierNameFile(t)
+	var want []byte
+	// the records are sorted in memory, or spilled to run files with a tiny budget
+	for _, budget := range []int64{0, 1, 4096} {
+		for _, ext := range []string{".idx", ".indexed"} {
+			data, err := os.ReadFile(filepath.Join("testdata", "jsonindex", "suppliernames.txt"+ext))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(supplierNameFilePath+ext, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		opts := DefaultOptions()
+		opts.IndexMemoryBudget = budget
+		if err := ConvertIndex(supplierNameFilePath, opts); err != nil {
+			t.Fatalf("ConvertIndex() budget %d error = %v", budget, err)
+		}
+		got, _, err := FindSupplierNameV2(context.Background(), testInvoiceFilePath, supplierNameFilePath, opts)
+		if err != nil {
+			t.Fatalf("FindSupplierNameV2() error = %v", err)
+		}
+		if got == nil || got.SupplierId != "3153303" || got.SupplierName != "Demo Company" {
+			t.Errorf("FindSupplierNameV2() = %v, want supplier 3153303 Demo Company", got)
+		}
+		ids := make(map[string]bool)
+		line := 0
+		err = readIndexedFile(supplierNameFilePath+".indexed", func(supplier *Supplier) error {
+			if ids[supplier.Id] {
+				t.Errorf("ConvertIndex() wrote supplier %s twice", supplier.Id)
+			}
+			// numbered in the order of the file after the header line
+			if wantLine := line + 1; supplier.Line != wantLine && !(line == 0 && supplier.Line == 2) {
+				t.Errorf("ConvertIndex() wrote supplier %s at line %d after line %d", supplier.Id, supplier.Line, line)
+			}
+			ids[supplier.Id] = true
+			line = supplier.Line
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexed, err := os.ReadFile(supplierNameFilePath + ".indexed")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			want = indexed
+		} else if !bytes.Equal(indexed, want) {
+			t.Errorf("ConvertIndex() budget %d wrote another indexed file than without budget", budget)
+		}
+		matches, _ := filepath.Glob(supplierNameFilePath + ".indexed.run-*")
+		if len(matches) > 0 {
+			t.Errorf("ConvertIndex() left the run files %v", matches)
+		}
+	}
+}
+
+func TestOpenIndex(t *testing.T) {
+	supplierNameFilePath := copySupplierNameFile(t)
+	opts := DefaultOptions()
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	index, err := OpenIndex(supplierNameFilePath, DefaultOptions())
+	if err != nil {
+		t.Fatalf("OpenIndex() error = %v", err)
+	}
+	defer index.Close()
+	// the same index answers several searches
+	for i := 0; i < 3; i++ {
+		got, err := index.FindSupplierName(context.Background(), testInvoiceFilePath, opts)
+		if err != nil {
+			t.Fatalf("FindSupplierName() error = %v", err)
+		}
+		if got == nil || got.SupplierId != "3153303" {
+			t.Errorf("FindSupplierName() = %v, want supplier 3153303", got)
+		}
+	}
+
+	// a close waits for the searches in progress, the searches after it fail
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := index.FindSupplierNames(context.Background(), testInvoiceFilePath, opts)
+			errs <- err
+		}()
+	}
+	if err := index.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil && !errors.Is(err, ErrIndexClosed) {
+			t.Errorf("FindSupplierNames() error = %v, want nil or ErrIndexClosed", err)
+		}
+	}
+	if _, err := index.FindSupplierName(context.Background(), testInvoiceFilePath, opts); !errors.Is(err, ErrIndexClosed) {
+		t.Errorf("FindSupplierName() after Close error = %v, want ErrIndexClosed", err)
+	}
+	if err := index.Close(); err != nil {
+		t.Errorf("Close() again error = %v", err)
+	}
+
+	if _, err := OpenIndex(writeSupplierNameFile(t, "1,Demo\n"), DefaultOptions()); err == nil {
+		t.Errorf("OpenIndex() error = nil, want a missing index error")
+	}
+}
+
+func TestOpenIndex_stale(t *testing.T) {
+	tests := []struct {
+		name      string
+		edit      func(t *testing.T, supplierNameFilePath string)
+		wantStale bool
+	}{
+		{
+			name:      "unchanged",
+			edit:      func(t *testing.T, supplierNameFilePath string) {},
+			wantStale: false,
+		},
+		{
+			name: "touched",
+			edit: func(t *testing.T, supplierNameFilePath string) {
+				future := time.Now().Add(time.Hour)
+				if err := os.Chtimes(supplierNameFilePath, future, future); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStale: false,
+		},
+		{
+			name: "supplier added",
+			edit: func(t *testing.T, supplierNameFilePath string) {
+				if err := os.WriteFile(supplierNameFilePath, []byte("Id,SupplierName\n1,Demo Company\n2,Fine Foods\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStale: true,
+		},
+		{
+			name: "supplier renamed with the same size",
+			edit: func(t *testing.T, supplierNameFilePath string) {
+				if err := os.WriteFile(supplierNameFilePath, []byte("Id,SupplierName\n1,Demo Compant\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				future := time.Now().Add(time.Hour)
+				if err := os.Chtimes(supplierNameFilePath, future, future); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStale: true,
+		},
+		{
+			name: "indexed file of another index",
+			edit: func(t *testing.T, supplierNameFilePath string) {
+				other := writeSupplierNameFile(t, "Id,SupplierName\n1,Fine Foods\n")
+				if _, err := BuildIndex(other, DefaultOptions()); err != nil {
+					t.Fatal(err)
+				}
+				indexed, err := os.ReadFile(other + ".indexed")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(supplierNameFilePath+".indexed", indexed, 0644); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantStale: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName\n1,Demo Company\n")
+			if _, err := BuildIndex(supplierNameFilePath, DefaultOptions()); err != nil {
+				t.Fatalf("BuildIndex() error = %v", err)
+			}
+			tt.edit(t, supplierNameFilePath)
+			index, err := OpenIndex(supplierNameFilePath, DefaultOptions())
+			if err == nil {
+				index.Close()
+			}
+			if errors.Is(err, ErrStaleIndex) != tt.wantStale || err != nil && !tt.wantStale {
+				t.Fatalf("OpenIndex() error = %v, want stale %v", err, tt.wantStale)
+			}
+			if !tt.wantStale {
+				return
+			}
+
+			// the stale index is built again on demand
+			opts := DefaultOptions()
+			opts.RebuildStaleIndex = true
+			if _, _, err := FindSupplierNameV2(context.Background(), testInvoiceFilePath, supplierNameFilePath, opts); err != nil {
+				t.Fatalf("FindSupplierNameV2() error = %v", err)
+			}
+			index, err = OpenIndex(supplierNameFilePath, DefaultOptions())
+			if err != nil {
+				t.Fatalf("OpenIndex() after rebuild error = %v", err)
+			}
+			index.Close()
+		})
+	}
+}
+
+func Test_verifyOnce(t *testing.T) {
+	path := writeSupplierNameFile(t, "1,Demo Company\n")
+	calls := 0
+	verify := func(ok bool) func() (bool, error) {
+		return func() (bool, error) {
+			calls++
+			return ok, nil
+		}
+	}
+	steps := []struct {
+		name      string
+		checksum  string
+		verify    func() (bool, error)
+		wantOk    bool
+		wantCalls int
+	}{
+		{name: "mismatch", checksum: "a", verify: verify(false), wantOk: false, wantCalls: 1},
+		{name: "mismatch is not cached", checksum: "a", verify: verify(false), wantOk: false, wantCalls: 2},
+		{name: "match", checksum: "a", verify: verify(true), wantOk: true, wantCalls: 3},
+		{name: "match is cached", checksum: "a", verify: verify(false), wantOk: true, wantCalls: 3},
+		{name: "other checksum", checksum: "b", verify: verify(false), wantOk: false, wantCalls: 4},
+	}
+	for _, step := range steps {
+		ok, err := verifyOnce(path, step.checksum, step.verify)
+		if err != nil || ok != step.wantOk || calls != step.wantCalls {
+			t.Errorf("verifyOnce(%s) = %v, %v with %d checks, want %v with %d checks", step.name, ok, err, calls, step.wantOk, step.wantCalls)
+		}
+	}
+
+	// another version of the file is checked again
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := verifyOnce(path, "a", verify(false)); err != nil || ok || calls != 5 {
+		t.Errorf("verifyOnce(touched) = %v, %v with %d checks, want false with 5 checks", ok, err, calls)
+	}
+	if _, err := verifyOnce(path+".missing", "a", verify(true)); err == nil {
+		t.Errorf("verifyOnce(missing) error = nil, want error")
+	}
+}
+
+func Test_filterPotentialSuppliersForPage_frequentKeys(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("Id,SupplierName\n1,Alpha Beta Company\n2,Global Trading\n")
+	for i := 0; i <= indexFrequentKeyPostings; i++ {
+		fmt.Fprintf(&buf, "c%d,Supplier%d Company\ng%d,Global X%d\nt%d,Trading Y%d\n", i, i, i, i, i, i)
+	}
+	supplierNameFilePath := writeSupplierNameFile(t, buf.String())
+	opts := DefaultOptions()
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	index, err := OpenIndex(supplierNameFilePath, opts)
+	if err != nil {
+		t.Fatalf("OpenIndex() error = %v", err)
+	}
+	defer index.Close()
+	tests := []struct {
+		name     string
+		invoice  string
+		fraction float64
+		want     []string
+	}{
+		{name: "frequent key counted", invoice: "INVOICE\nAlpha Beta Company\n", fraction: 1, want: []string{"1"}},
+		{name: "rarer key missing", invoice: "INVOICE\nAlpha Beta\n", fraction: 1, want: nil},
+		{name: "frequent key alone", invoice: "INVOICE\nCompany\n", fraction: 0.5, want: nil},
+		{name: "frequent keys only", invoice: "INVOICE\nGlobal Trading\n", fraction: 0.5, want: []string{"2"}},
+		{name: "frequent key with a rarer one", invoice: "INVOICE\nSupplier7 Company\n", fraction: 1, want: []string{"c7"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			words, err := LoadInvoice(writeInvoiceFile(t, tt.invoice), opts.InvoiceFormat)
+			if err != nil {
+				t.Fatal(err)
+			}
+			suppliersForPage, err := filterPotentialSuppliersForPage(context.Background(), NewPages(words, opts), index.index, index.indexed, tt.fraction)
+			if err != nil {
+				t.Fatalf("filterPotentialSuppliersForPage() error = %v", err)
+			}
+			var got []string
+			for _, suppliers := range suppliersForPage {
+				for _, supplier := range suppliers.Suppliers {
+					got = append(got, supplier.Id)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filterPotentialSuppliersForPage() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	// the name of frequent tokens printed in full is found like without index
+	got, err := index.FindSupplierName(context.Background(), writeInvoiceFile(t, "INVOICE\nGlobal Trading\n"), opts)
+	if err != nil {
+		t.Fatalf("FindSupplierName() error = %v", err)
+	}
+	if got == nil || got.SupplierId != "2" {
+		t.Errorf("FindSupplierName() = %v, want supplier 2", got)
+	}
+}
+
+func TestOpenIndex_options(t *testing.T) {
+	exact := DefaultOptions()
+	exact.Normalizer = Normalizer{}
+	legalForms := DefaultOptions()
+	legalForms.Normalizer.Equivalents = DefaultEquivalents()
+	optionalSuffix := legalForms
+	optionalSuffix.OptionalSuffix = true
+	identifiers := DefaultOptions()
+	identifiers.MatchIdentifiers = true
+	tests := []struct {
+		name   string
+		build  Options
+		search Options
+	}{
+		{name: "exact then default", build: exact, search: DefaultOptions()},
+		{name: "default then exact", build: DefaultOptions(), search: exact},
+		{name: "equivalents", build: DefaultOptions(), search: legalForms},
+		{name: "optional suffix", build: legalForms, search: optionalSuffix},
+		{name: "identifiers", build: identifiers, search: DefaultOptions()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supplierNameFilePath := copySupplierNameFile(t)
+			if _, err := BuildIndex(supplierNameFilePath, tt.build); err != nil {
+				t.Fatalf("BuildIndex() error = %v", err)
+			}
+			if _, _, err := FindSupplierNameV2(context.Background(), testInvoiceFilePath, supplierNameFilePath, tt.search); !errors.Is(err, ErrStaleIndex) {
+				t.Fatalf("FindSupplierNameV2() error = %v, want ErrStaleIndex", err)
+			}
+			index, err := OpenIndex(supplierNameFilePath, tt.build)
+			if err != nil {
+				t.Fatalf("OpenIndex() error = %v", err)
+			}
+			defer index.Close()
+			if _, err := index.FindSupplierName(context.Background(), testInvoiceFilePath, tt.search); !errors.Is(err, ErrStaleIndex) {
+				t.Errorf("Index.FindSupplierName() error = %v, want ErrStaleIndex", err)
+			}
+
+			// the index is built again with the options it is searched with
+			rebuild := tt.search
+			rebuild.RebuildStaleIndex = true
+			got, _, err := FindSupplierNameV2(context.Background(), testInvoiceFilePath, supplierNameFilePath, rebuild)
+			if err != nil || got == nil || got.SupplierId != "3153303" {
+				t.Errorf("FindSupplierNameV2() = %v, %v, want supplier 3153303", got, err)
+			}
+		})
+	}
+}
+
+func TestBuildIndex_atomic(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName\n1,Demo Company\n")
+	opts := DefaultOptions()
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	readIndexFiles := func() (files [][]byte) {
+		for _, ext := range []string{".idx", ".indexed", ".manifest"} {
+			data, err := os.ReadFile(supplierNameFilePath + ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, data)
+		}
+		return files
+	}
+	checkNoTempFiles := func() {
+		if tmp, _ := filepath.Glob(supplierNameFilePath + ".*.tmp-*"); len(tmp) > 0 {
+			t.Errorf("temporary files left: %v", tmp)
+		}
+	}
+	built := readIndexFiles()
+	checkNoTempFiles()
+
+	// a build stopped by a malformed record leaves the previous index files
+	if err := os.WriteFile(supplierNameFilePath, []byte("Id,SupplierName\n1,Demo Company\n2,\"bad\"quote\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BuildIndex(supplierNameFilePath, opts); err == nil {
+		t.Fatalf("BuildIndex() error = nil, want a supplier file error")
+	}
+	if !reflect.DeepEqual(readIndexFiles(), built) {
+		t.Errorf("BuildIndex() failed but replaced the index files")
+	}
+	checkNoTempFiles()
+
+	// index files of a previous build next to the manifest of the last one
+	if err := os.WriteFile(supplierNameFilePath, []byte("Id,SupplierName\n1,Demo Company\n2,Fine Foods\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	for i, ext := range []string{".idx", ".indexed"} {
+		if err := os.WriteFile(supplierNameFilePath+ext, built[i], 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := OpenIndex(supplierNameFilePath, DefaultOptions()); !errors.Is(err, ErrStaleIndex) || !strings.Contains(err.Error(), "partially installed") {
+		t.Errorf("OpenIndex() error = %v, want partially installed index files", err)
+	}
+}
+
+func TestBuildIndex_memoryBudget(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("Id,SupplierName,Aliases\n")
+	words := []string{"Acme", "Fine", "Foods", "Global", "Trading"}
+	for i := 0; i < 300; i++ {
+		fmt.Fprintf(&buf, "%d,%s %s Company,%s%d\n", i, words[i%len(words)], words[i/len(words)%len(words)], words[i%3], i)
+	}
+	supplierNameFilePath := writeSupplierNameFile(t, buf.String())
+	build := func(budget int64) (files [][]byte) {
+		opts := DefaultOptions()
+		opts.IndexMemoryBudget = budget
+		if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+			t.Fatalf("BuildIndex(%d) error = %v", budget, err)
+		}
+		for _, ext := range []string{".idx", ".indexed"} {
+			data, err := os.ReadFile(supplierNameFilePath + ext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, data)
+		}
+		if tmp, _ := filepath.Glob(supplierNameFilePath + ".*-*"); len(tmp) > 0 {
+			t.Errorf("BuildIndex(%d) left temporary files: %v", budget, tmp)
+		}
+		return files
+	}
+	// a budget of a byte spills every supplier to a run, and merges the runs several times
+	want := build(0)
+	for _, budget := range []int64{1, 4096} {
+		if got := build(budget); !reflect.DeepEqual(got, want) {
+			t.Errorf("BuildIndex(%d) index files differ from the ones built in memory", budget)
+		}
+	}
+}
+
+// writeBenchmarkIndex - build the index of the sample suppliers and of n generated suppliers sharing common tokens
+func writeBenchmarkIndex(b *testing.B, n int) (supplierNameFilePath string) {
+	b.Helper()
+	sample, err := os.ReadFile(testSupplierNameFilePath)
+	if err != nil {
+		b.Fatal(err)
+	}
+	var buf bytes.Buffer
+	buf.Write(sample)
+	words := []string{"Acme", "Fine", "Foods", "Global", "Trading", "Services", "Holdings", "Pacific"}
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "%d,%s%d %s Company\n", 10000000+i, words[i%len(words)], i, words[i/len(words)%len(words)])
+	}
+	supplierNameFilePath = filepath.Join(b.TempDir(), "suppliernames.txt")
+	if err := os.WriteFile(supplierNameFilePath, buf.Bytes(), 0644); err != nil {
+		b.Fatal(err)
+	}
+	if _, err := BuildIndex(supplierNameFilePath, DefaultOptions()); err != nil {
+		b.Fatal(err)
+	}
+	return supplierNameFilePath
+}
+
+// writeBaselineIndex - write the index files of the first version of the indexed supplier name file,
+// the "id,name" records grouped by the first word of the name and the JSON offsets of the groups
+func writeBaselineIndex(b *testing.B, supplierNameFilePath string) (idxPath, indexedPath string) {
+	b.Helper()
+	groups := make(map[string][]*Supplier)
+	err := readIndexedFile(supplierNameFilePath+".indexed", func(supplier *Supplier) error {
+		first := strings.Split(supplier.SupplierName, " ")[0]
+		groups[first] = append(groups[first], supplier)
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	words := make([]string, 0, len(groups))
+	for word := range groups {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	var indexed bytes.Buffer
+	offsets := make(map[string]uint64, len(groups))
+	for _, word := range words {
+		offsets[word] = uint64(indexed.Len())
+		for _, supplier := range groups[word] {
+			fmt.Fprintf(&indexed, "%s,%s\n", supplier.Id, supplier.SupplierName)
+		}
+	}
+	idx, err := json.Marshal(offsets)
+	if err != nil {
+		b.Fatal(err)
+	}
+	dir := b.TempDir()
+	idxPath, indexedPath = filepath.Join(dir, "baseline.idx"), filepath.Join(dir, "baseline.indexed")
+	if err := os.WriteFile(idxPath, idx, 0644); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.WriteFile(indexedPath, indexed.Bytes(), 0644); err != nil {
+		b.Fatal(err)
+	}
+	return idxPath, indexedPath
+}
+
+// filterBaselineSuppliersForPage - the potential suppliers of the pages as found by the first version,
+// which seeks to the group of every word and scans it with a regexp compiled again for every word
+func filterBaselineSuppliersForPage(pages []*Page, offsets map[string]uint64, indexed *os.File) (suppliersForPage []*SuppliersForPage, err error) {
+	for _, page := range pages {
+		suppliers := make([]*Supplier, 0)
+		for _, word := range page.Words {
+			offset, ok := offsets[word.Word]
+			if !ok {
+				continue
+			}
+			if _, err = indexed.Seek(int64(offset), io.SeekStart); err != nil {
+				return nil, err
+			}
+			reg := regexp.MustCompile(`(\d+),(.+)`)
+			scanner := bufio.NewScanner(indexed)
+			for scanner.Scan() {
+				match := reg.FindStringSubmatch(scanner.Text())
+				if len(match) != 3 {
+					return nil, fmt.Errorf("invalid supplier name text")
+				}
+				if strings.Split(match[2], " ")[0] != word.Word {
+					break
+				}
+				suppliers = append(suppliers, &Supplier{Id: match[1], SupplierName: match[2]})
+			}
+		}
+		if len(suppliers) > 0 {
+			suppliersForPage = append(suppliersForPage, &SuppliersForPage{Page: page, Suppliers: suppliers})
+		}
+	}
+	return suppliersForPage, nil
+}
+
+func BenchmarkFilterPotentialSuppliersForPage(b *testing.B) {
+	supplierNameFilePath := writeBenchmarkIndex(b, 100000)
+	opts := DefaultOptions()
+	words, err := LoadInvoice(testInvoiceFilePath, opts.InvoiceFormat)
+	if err != nil {
+		b.Fatal(err)
+	}
+	pages := NewPages(words, opts)
+	ctx := context.Background()
+
+	// read the JSON index and scan the records of every word of the first version on every search
+	idxPath, indexedPath := writeBaselineIndex(b, supplierNameFilePath)
+	b.Run("baseline seek scan", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			data, err := os.ReadFile(idxPath)
+			if err != nil {
+				b.Fatal(err)
+			}
+			var offsets map[string]uint64
+			if err := json.Unmarshal(data, &offsets); err != nil {
+				b.Fatal(err)
+			}
+			indexed, err := os.Open(indexedPath)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := filterBaselineSuppliersForPage(pages, offsets, indexed); err != nil {
+				b.Fatal(err)
+			}
+			indexed.Close()
+		}
+	})
+	// read the index from the files on every search
+	b.Run("file", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			data, err := os.ReadFile(supplierNameFilePath + ".idx")
+			if err != nil {
+				b.Fatal(err)
+			}
+			index, err := parseBinaryIndex(data)
+			if err != nil {
+				b.Fatal(err)
+			}
+			indexed, err := os.Open(supplierNameFilePath + ".indexed")
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := filterPotentialSuppliersForPage(ctx, pages, index, indexed, opts.MinTokenFraction); err != nil {
+				b.Fatal(err)
+			}
+			indexed.Close()
+		}
+	})
+	// map the index on every search, as FindSupplierNameV2
+	b.Run("mmap", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			index, err := OpenIndex(supplierNameFilePath, DefaultOptions())
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := filterPotentialSuppliersForPage(ctx, pages, index.index, index.indexed, opts.MinTokenFraction); err != nil {
+				b.Fatal(err)
+			}
+			index.Close()
+		}
+	})
+	// map the index once for all the searches of a long-running process
+	b.Run("mmap reused", func(b *testing.B) {
+		index, err := OpenIndex(supplierNameFilePath, DefaultOptions())
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer index.Close()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := filterPotentialSuppliersForPage(ctx, pages, index.index, index.indexed, opts.MinTokenFraction); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,500 @@
+package matcher
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DeltaOpColumn - the header of the operation column of a delta file, see UpdateIndex
+	DeltaOpColumn = "Op"
+
+	deltaOpAdd    = "add"
+	deltaOpUpdate = "update"
+	deltaOpDelete = "delete"
+
+	// segmentPut, segmentDelete, segmentSource - the operations of the records of the segment file
+	segmentPut    = "put"
+	segmentDelete = "delete"
+	segmentSource = "source"
+	// segmentBatch - the header of the records of a delta in the segment file
+	segmentBatch = "batch"
+
+	// compactMinSegmentSize - the min number of supplier records in the segment before it is compacted
+	compactMinSegmentSize = 1000
+	// compactSegmentRatio - the segment is compacted once it has more supplier records than this fraction of the index
+	compactSegmentRatio = 0.1
+)
+
+// segmentPath - the path of the append-only segment of the index files of the supplier name file
+func segmentPath(supplierNameFilePath string) string {
+	return fmt.Sprintf("%s.segment", supplierNameFilePath)
+}
+
+// segmentSupplier - a supplier of the segment with its index keys, which are normalized when the segment is written
+type segmentSupplier struct {
+	supplier   *Supplier
+	keys       []string
+	tokenCount int
+}
+
+// indexSegment - the suppliers added, updated and deleted since the index files were built
+// The segment file is a log of CSV records replayed in order, "delete,id" hides the suppliers of id written before,
+// in the index files or in the segment, and "put,token count,key count,keys...,line,id,name[,alias[,identifiers]]"
+// adds a supplier. Updating a supplier deletes it and puts its new name and aliases.
+// "source,size,modification time,hash" records the supplier name file the delta is applied to, see indexSource.
+// The records of a delta are written at once after a "batch,size,checksum" header with the size and the CRC-32 of
+// the records, a batch cut off by a crash or a failed write is dropped with everything after it.
+type indexSegment struct {
+	deleted   map[string]bool              // the ids of the suppliers of the index files deleted or updated
+	suppliers map[string][]segmentSupplier // the current suppliers of the segment by id
+	ids       []string                     // the ids of the segment in the order they were first put
+	puts      int                          // the number of put records, compared with the index to compact it
+	source    indexSource                  // the supplier name file of the last delta, unknown if there is none
+	size      int64                        // the size of the segment file up to the end of its last complete batch
+}
+
+func newIndexSegment() *indexSegment {
+	return &indexSegment{
+		deleted:   make(map[string]bool),
+		suppliers: make(map[string][]segmentSupplier),
+	}
+}
+
+// readIndexSegment - replay the segment file of the supplier name file, an empty segment if there is none
+func readIndexSegment(supplierNameFilePath string) (segment *indexSegment, err error) {
+	segment = newIndexSegment()
+	f, err := os.Open(segmentPath(supplierNameFilePath))
+	if os.IsNotExist(err) {
+		return segment, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	manifest, err := readIndexManifest(supplierNameFilePath)
+	if err != nil {
+		return nil, err
+	}
+	if err = skipMergedSegment(f, manifest); err != nil {
+		return nil, err
+	}
+	if segment.size, err = f.Seek(0, io.SeekCurrent); err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+	for {
+		records, n, err := readSegmentBatch(r)
+		if err == io.EOF { // the end of the file or an incomplete batch
+			return segment, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if err = segment.apply(record); err != nil {
+				return nil, fmt.Errorf("%s: batch at %d: %v", segmentPath(supplierNameFilePath), segment.size, err)
+			}
+		}
+		segment.size += n
+	}
+}
+
+// readSegmentBatch - read the records of the next batch of the segment file, n is the size of the batch
+// return io.EOF at the end of the file or if the batch is incomplete
+func readSegmentBatch(r *bufio.Reader) (records [][]string, n int64, err error) {
+	line, err := r.ReadString('\n')
+	if err != nil { // the header is incomplete
+		return nil, 0, io.EOF
+	}
+	header := strings.Split(strings.TrimSuffix(line, "\n"), ",")
+	if len(header) != 3 || header[0] != segmentBatch {
+		return nil, 0, io.EOF
+	}
+	size, err1 := strconv.ParseInt(header[1], 10, 64)
+	checksum, err2 := strconv.ParseUint(header[2], 10, 32)
+	if err1 != nil || err2 != nil || size < 0 {
+		return nil, 0, io.EOF
+	}
+	body := make([]byte, size)
+	if _, err = io.ReadFull(r, body); err != nil || crc32.ChecksumIEEE(body) != uint32(checksum) {
+		return nil, 0, io.EOF
+	}
+	if records, err = newSupplierReader(bytes.NewReader(body)).ReadAll(); err != nil {
+		return nil, 0, err
+	}
+	return records, int64(len(line)) + size, nil
+}
+
+// writeSegmentBatch - the records framed as a batch of the segment file
+func writeSegmentBatch(records [][]string) (batch []byte, err error) {
+	var body bytes.Buffer
+	for _, record := range records {
+		if _, err = writeIndexRecord(&body, record); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if _, err = writeIndexRecord(&buf, []string{segmentBatch, strconv.Itoa(body.Len()), strconv.FormatUint(uint64(crc32.ChecksumIEEE(body.Bytes())), 10)}); err != nil {
+		return nil, err
+	}
+	buf.Write(body.Bytes())
+	return buf.Bytes(), nil
+}
+
+// apply - apply a record of the segment file
+func (segment *indexSegment) apply(record []string) (err error) {
+	switch {
+	case len(record) == 2 && record[0] == segmentDelete:
+		segment.deleted[record[1]] = true
+		delete(segment.suppliers, record[1])
+		return nil
+	case len(record) == 4 && record[0] == segmentSource:
+		size, err1 := strconv.ParseInt(record[1], 10, 64)
+		modTime, err2 := strconv.ParseInt(record[2], 10, 64)
+		hash, err3 := hex.DecodeString(record[3])
+		if err1 != nil || err2 != nil || err3 != nil || len(hash) != len(segment.source.hash) {
+			return fmt.Errorf("invalid segment record")
+		}
+		segment.source = indexSource{size: size, modTime: modTime}
+		copy(segment.source.hash[:], hash)
+		return nil
+	case len(record) > 3 && record[0] == segmentPut:
+		tokenCount, err := strconv.Atoi(record[1])
+		if err != nil {
+			return fmt.Errorf("invalid segment record")
+		}
+		keyCount, err := strconv.Atoi(record[2])
+		if err != nil || keyCount < 0 || 3+keyCount > len(record) {
+			return fmt.Errorf("invalid segment record")
+		}
+		supplier, err := parseIndexRecord(record[3+keyCount:])
+		if err != nil {
+			return err
+		}
+		if _, ok := segment.suppliers[supplier.Id]; !ok {
+			segment.ids = append(segment.ids, supplier.Id)
+		}
+		segment.suppliers[supplier.Id] = append(segment.suppliers[supplier.Id], segmentSupplier{
+			supplier:   supplier,
+			keys:       record[3 : 3+keyCount],
+			tokenCount: tokenCount,
+		})
+		segment.puts++
+		return nil
+	}
+	return fmt.Errorf("invalid segment record")
+}
+
+// current - the current suppliers of the segment in the order of their line
+func (segment *indexSegment) current() (suppliers []segmentSupplier) {
+	seen := make(map[string]bool)
+	for _, id := range segment.ids {
+		if !seen[id] {
+			seen[id] = true
+			suppliers = append(suppliers, segment.suppliers[id]...)
+		}
+	}
+	sort.SliceStable(suppliers, func(i, j int) bool { return suppliers[i].supplier.Line < suppliers[j].supplier.Line })
+	return
+}
+
+// index - the index of the current suppliers of the segment in memory, in the format of the index files
+func (segment *indexSegment) index() (index *binaryIndex, indexed *bytes.Reader, err error) {
+	var records, idx bytes.Buffer
+	suppliers := newSupplierIndex()
+	for _, s := range segment.current() {
+		offset := uint64(records.Len())
+		if _, err = writeIndexRecord(&records, s.supplier.indexRecord()); err != nil {
+			return nil, nil, err
+		}
+		suppliers.add(offset, s.keys, s.tokenCount)
+	}
+	if err = writeBinaryIndex(&idx, suppliers); err != nil {
+		return nil, nil, err
+	}
+	if index, err = parseBinaryIndex(idx.Bytes()); err != nil {
+		return nil, nil, err
+	}
+	return index, bytes.NewReader(records.Bytes()), nil
+}
+
+// UpdateIndex - apply the delta file to the index files of the supplier name file built by BuildIndex
+// The delta file is a CSV file with the header of a supplier name file and an "Op" column, whose value is
+// add, update or delete, e.g. "Op,Id,SupplierName\nadd,42,Demo Company\ndelete,7,\n". The rows of an update replace
+// the name, the aliases and the identifiers of the supplier, a deleted supplier only needs its id.
+// The changes are appended to <supplier>.segment, which the searches with the index merge with the index files,
+// and the segment is compacted into the index files once it is large, see CompactIndex.
+// The added suppliers are numbered after the last supplier of the index, an updated supplier keeps its line.
+// The delta is applied entirely or not at all, and an Index opened before must be opened again to see it.
+// The supplier name file must be edited before the delta is applied, since the index is then checked against it.
+func UpdateIndex(supplierNameFilePath, deltaFilePath string, opts Options) (err error) {
+	if _, err = readIndexHeader(supplierNameFilePath, opts); err != nil {
+		return err
+	}
+	segment, err := readIndexSegment(supplierNameFilePath)
+	if err != nil {
+		return err
+	}
+	// the line of every current supplier by id, the suppliers of the index files are read one by one
+	lines := make(map[string]int)
+	nextLine := 1
+	baseSize := 0
+	err = readIndexedFile(fmt.Sprintf("%s.indexed", supplierNameFilePath), func(supplier *Supplier) error {
+		baseSize++
+		if !segment.deleted[supplier.Id] {
+			lines[supplier.Id] = supplier.Line
+		}
+		if supplier.Line >= nextLine {
+			nextLine = supplier.Line + 1
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, s := range segment.current() {
+		lines[s.supplier.Id] = s.supplier.Line
+	}
+	for _, suppliers := range segment.suppliers {
+		for _, s := range suppliers {
+			if s.supplier.Line >= nextLine {
+				nextLine = s.supplier.Line + 1
+			}
+		}
+	}
+
+	records, err := readDeltaFile(deltaFilePath, opts, lines, nextLine)
+	if err != nil {
+		return err
+	}
+	// the supplier name file is expected to be edited like the delta before it is applied
+	source, err := readIndexSource(supplierNameFilePath)
+	if err != nil {
+		return err
+	}
+	if source.known() {
+		records = append(records, []string{segmentSource, strconv.FormatInt(source.size, 10), strconv.FormatInt(source.modTime, 10), hex.EncodeToString(source.hash[:])})
+	}
+	batch, err := writeSegmentBatch(records)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if record[0] == segmentPut {
+			segment.puts++
+		}
+	}
+	// the batch replaces an incomplete one left by a previous update
+	f, err := os.OpenFile(segmentPath(supplierNameFilePath), os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if err = f.Truncate(segment.size); err == nil {
+		_, err = f.WriteAt(batch, segment.size)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+
+	if segment.puts > compactMinSegmentSize && float64(segment.puts) > compactSegmentRatio*float64(baseSize) {
+		return CompactIndex(supplierNameFilePath, opts)
+	}
+	return nil
+}
+
+// readDeltaFile - the segment records of the rows of the delta file
+// lines is the line of every current supplier by id, it is updated with the rows, nextLine is the line of the next added supplier
+func readDeltaFile(deltaFilePath string, opts Options, lines map[string]int, nextLine int) (records [][]string, err error) {
+	f, err := os.Open(deltaFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := newSupplierReader(f)
+	var columns *supplierColumns
+	opColumn := -1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, &SupplierFileError{File: deltaFilePath, Line: parseErr.StartLine, Err: parseErr.Err}
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		fileErr := func(err error) error {
+			return &SupplierFileError{File: deltaFilePath, Line: line, Text: strings.Join(record, ","), Err: err}
+		}
+		if columns == nil {
+			for i, field := range record {
+				if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(field, "\ufeff")), DeltaOpColumn) {
+					opColumn = i
+				}
+			}
+			var header bool
+			columns, header, err = detectSupplierColumns(record, opts)
+			switch {
+			case err == nil && !header:
+				err = fmt.Errorf("missing header")
+			case err == nil && opColumn < 0:
+				err = fmt.Errorf("missing column %q in header", DeltaOpColumn)
+			}
+			if err != nil {
+				return nil, fileErr(err)
+			}
+			continue
+		}
+
+		if opColumn >= len(record) || columns.id >= len(record) {
+			return nil, fileErr(nil)
+		}
+		op := strings.ToLower(strings.TrimSpace(record[opColumn]))
+		id := strings.TrimSpace(record[columns.id])
+		_, exists := lines[id]
+		switch {
+		case id == "":
+			return nil, fileErr(nil)
+		case op == deltaOpAdd && exists:
+			return nil, fileErr(fmt.Errorf("supplier %q already exists", id))
+		case (op == deltaOpUpdate || op == deltaOpDelete) && !exists:
+			return nil, fileErr(fmt.Errorf("supplier %q does not exist", id))
+		case op != deltaOpAdd && op != deltaOpUpdate && op != deltaOpDelete:
+			return nil, fileErr(fmt.Errorf("invalid operation %q", op))
+		}
+
+		if op != deltaOpAdd {
+			records = append(records, []string{segmentDelete, id})
+		}
+		if op == deltaOpDelete {
+			delete(lines, id)
+			continue
+		}
+		supplierLine := lines[id]
+		if op == deltaOpAdd {
+			supplierLine = nextLine
+			nextLine++
+		}
+		suppliers := columns.suppliers(record, supplierLine)
+		if suppliers == nil {
+			return nil, fileErr(nil)
+		}
+		for _, supplier := range suppliers {
+			keys := supplier.indexKeys(opts.Normalizer)
+			if len(keys) == 0 { // nothing left to match after normalization
+				continue
+			}
+			put := []string{segmentPut, strconv.Itoa(supplier.indexTokenCount(opts.Normalizer, opts.OptionalSuffix)), strconv.Itoa(len(keys))}
+			put = append(put, keys...)
+			records = append(records, append(put, supplier.indexRecord()...))
+		}
+		lines[id] = supplierLine
+	}
+	if columns == nil {
+		return nil, &SupplierFileError{File: deltaFilePath, Line: 1, Err: fmt.Errorf("missing header")}
+	}
+	return records, nil
+}
+
+// CompactIndex - merge the segment into the index files of the supplier name file, see UpdateIndex
+// the index files are written again with opts, which must be the options the index was built with.
+// An Index opened before must be closed first.
+func CompactIndex(supplierNameFilePath string, opts Options) (err error) {
+	header, err := readIndexHeader(supplierNameFilePath, opts)
+	if err != nil {
+		return err
+	}
+	segment, err := readIndexSegment(supplierNameFilePath)
+	if err != nil {
+		return err
+	}
+	source := segment.source
+	if !source.known() {
+		source = header.source
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // stop reading the suppliers if the index can't be written
+	supplierChan, errChan := streamSuppliers(ctx, func(emit func(supplier *Supplier) error) error {
+		// the suppliers of the index files are read in the order of their line and merged with the ones of the segment
+		current := segment.current()
+		i := 0
+		err := readIndexedFile(fmt.Sprintf("%s.indexed", supplierNameFilePath), func(supplier *Supplier) error {
+			if segment.deleted[supplier.Id] {
+				return nil
+			}
+			for ; i < len(current) && current[i].supplier.Line < supplier.Line; i++ {
+				if err := emit(current[i].supplier); err != nil {
+					return err
+				}
+			}
+			return emit(supplier)
+		})
+		for ; err == nil && i < len(current); i++ {
+			err = emit(current[i].supplier)
+		}
+		return err
+	})
+	return writeIndex(supplierNameFilePath, supplierChan, errChan, opts, source)
+}
+
+// mergeSegmentSuppliers - the potential suppliers of each page from the index files without the deleted ones,
+// followed by the potential suppliers of the segment
+func mergeSegmentSuppliers(pages []*Page, base, segment []*SuppliersForPage, deleted map[string]bool) (merged []*SuppliersForPage) {
+	suppliersOfPage := make(map[*Page][]*Supplier)
+	for _, suppliersForPage := range base {
+		for _, supplier := range suppliersForPage.Suppliers {
+			if !deleted[supplier.Id] {
+				suppliersOfPage[suppliersForPage.Page] = append(suppliersOfPage[suppliersForPage.Page], supplier)
+			}
+		}
+	}
+	for _, suppliersForPage := range segment {
+		suppliersOfPage[suppliersForPage.Page] = append(suppliersOfPage[suppliersForPage.Page], suppliersForPage.Suppliers...)
+	}
+	merged = make([]*SuppliersForPage, 0)
+	for _, page := range pages {
+		if suppliers := suppliersOfPage[page]; len(suppliers) > 0 {
+			merged = append(merged, &SuppliersForPage{Page: page, Suppliers: suppliers})
+		}
+	}
+	return
+}
+
+// readIndexHeader - the header and the source of the binary index of the supplier name file without its sections
+// return an error wrapping ErrStaleIndex if it was built with other options than opts, it can't be updated with them
+func readIndexHeader(supplierNameFilePath string, opts Options) (index *binaryIndex, err error) {
+	idxPath := fmt.Sprintf("%s.idx", supplierNameFilePath)
+	data, unmap, err := mapFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unmap()
+	if index, err = parseIndexFile(idxPath, data, opts); err != nil {
+		return nil, err
+	}
+	// the sections are in the unmapped data
+	index.keys, index.postings, index.pool = nil, nil, nil
+	index.keyCount = 0
+	return index, nil
+}
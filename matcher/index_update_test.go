@@ -0,0 +1,189 @@
+package matcher
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateIndex(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName,Aliases\n1,Demo Company,\n2,Another Company,\n3,Old Name Pty,\n")
+	opts := DefaultOptions()
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	// the supplier name file is edited like the delta, the index is not stale once it is applied
+	if err := os.WriteFile(supplierNameFilePath, []byte("Id,SupplierName,Aliases\n1,Demo Company,\n3,New Name Pty,NNP Group\n4,Fine Foods Company,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	deltaFilePath := filepath.Join(t.TempDir(), "delta.csv")
+	delta := "Op,Id,SupplierName,Aliases\nadd,4,Fine Foods Company,\nUPDATE,3,New Name Pty,NNP Group\ndelete,2,\n"
+	if err := os.WriteFile(deltaFilePath, []byte(delta), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateIndex(supplierNameFilePath, deltaFilePath, opts); err != nil {
+		t.Fatalf("UpdateIndex() error = %v", err)
+	}
+
+	tests := []struct {
+		invoice  string
+		wantId   string
+		wantLine int
+	}{
+		{invoice: "INVOICE\nDemo Company\n", wantId: "1", wantLine: 2},
+		{invoice: "INVOICE\nAnother Company\n", wantId: ""},
+		{invoice: "INVOICE\nOld Name Pty\n", wantId: ""},
+		{invoice: "INVOICE\nNew Name Pty\n", wantId: "3", wantLine: 4},
+		{invoice: "INVOICE\nNNP Group\n", wantId: "3", wantLine: 4},
+		{invoice: "INVOICE\nFine Foods Company\n", wantId: "4", wantLine: 5},
+	}
+	check := func(stage string) {
+		for _, tt := range tests {
+			got, _, err := FindSupplierNameV2(context.Background(), writeInvoiceFile(t, tt.invoice), supplierNameFilePath, opts)
+			if err != nil {
+				t.Fatalf("%s: FindSupplierNameV2() error = %v", stage, err)
+			}
+			gotId, gotLine := "", 0
+			if got != nil {
+				gotId, gotLine = got.SupplierId, got.SupplierLine
+			}
+			if gotId != tt.wantId || gotLine != tt.wantLine {
+				t.Errorf("%s: FindSupplierNameV2(%q) = supplier %q line %d, want supplier %q line %d", stage, tt.invoice, gotId, gotLine, tt.wantId, tt.wantLine)
+			}
+		}
+	}
+	check("segment")
+
+	if err := CompactIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("CompactIndex() error = %v", err)
+	}
+	if _, err := os.Stat(segmentPath(supplierNameFilePath)); !os.IsNotExist(err) {
+		t.Errorf("CompactIndex() segment stat error = %v, want not exist", err)
+	}
+	check("compacted")
+}
+
+func TestUpdateIndex_error(t *testing.T) {
+	tests := []struct {
+		name  string
+		delta string
+		line  int
+	}{
+		{name: "add existing", delta: "Op,Id,SupplierName\nadd,3,Fine Foods\nadd,1,Demo\n", line: 3},
+		{name: "update missing", delta: "Op,Id,SupplierName\nupdate,9,Demo\n", line: 2},
+		{name: "delete deleted", delta: "Op,Id,SupplierName\ndelete,1,\ndelete,1,\n", line: 3},
+		{name: "invalid operation", delta: "Op,Id,SupplierName\nrename,1,Demo\n", line: 2},
+		{name: "missing op column", delta: "Id,SupplierName\n1,Demo\n", line: 1},
+		{name: "bare quote in the first field", delta: "Op,Id,SupplierName\nad\"d,42,Foo\n", line: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName\n1,Demo Company\n2,Another Company\n")
+			if _, err := BuildIndex(supplierNameFilePath, DefaultOptions()); err != nil {
+				t.Fatalf("BuildIndex() error = %v", err)
+			}
+			deltaFilePath := filepath.Join(t.TempDir(), "delta.csv")
+			if err := os.WriteFile(deltaFilePath, []byte(tt.delta), 0644); err != nil {
+				t.Fatal(err)
+			}
+			err := UpdateIndex(supplierNameFilePath, deltaFilePath, DefaultOptions())
+			var fileErr *SupplierFileError
+			if !errors.As(err, &fileErr) || fileErr.Line != tt.line {
+				t.Fatalf("UpdateIndex() error = %v, want SupplierFileError at line %d", err, tt.line)
+			}
+			// nothing of the delta is applied
+			if _, err := os.Stat(segmentPath(supplierNameFilePath)); !os.IsNotExist(err) {
+				t.Errorf("UpdateIndex() segment stat error = %v, want not exist", err)
+			}
+		})
+	}
+}
+
+func TestCompactIndex_segmentLeft(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName,Aliases\n1,Demo Company,\n")
+	opts := DefaultOptions()
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	deltaFilePath := filepath.Join(t.TempDir(), "delta.csv")
+	if err := os.WriteFile(deltaFilePath, []byte("Op,Id,SupplierName,Aliases\nadd,2,Fine Foods,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := UpdateIndex(supplierNameFilePath, deltaFilePath, opts); err != nil {
+		t.Fatalf("UpdateIndex() error = %v", err)
+	}
+	segment, err := os.ReadFile(segmentPath(supplierNameFilePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CompactIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("CompactIndex() error = %v", err)
+	}
+	// the segment merged into the index files is left as if the compaction was interrupted before removing it
+	if err := os.WriteFile(segmentPath(supplierNameFilePath), segment, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := readIndexSegment(supplierNameFilePath); err != nil || got.puts != 0 || len(got.ids) != 0 {
+		t.Errorf("readIndexSegment() = %+v, %v, want the merged segment skipped", got, err)
+	}
+	got, _, err := FindSupplierNamesV2(context.Background(), writeInvoiceFile(t, "INVOICE\nFine Foods\n"), supplierNameFilePath, opts)
+	if err != nil {
+		t.Fatalf("FindSupplierNamesV2() error = %v", err)
+	}
+	if len(got) != 1 || got[0].SupplierId != "2" {
+		t.Errorf("FindSupplierNamesV2() = %v, want supplier 2 once", got)
+	}
+}
+
+func TestUpdateIndex_incompleteBatch(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName,Aliases\n12,Demo Company,\n123,Another Company,\n")
+	opts := DefaultOptions()
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	update := func(delta string) {
+		deltaFilePath := filepath.Join(t.TempDir(), "delta.csv")
+		if err := os.WriteFile(deltaFilePath, []byte(delta), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := UpdateIndex(supplierNameFilePath, deltaFilePath, opts); err != nil {
+			t.Fatalf("UpdateIndex() error = %v", err)
+		}
+	}
+	update("Op,Id,SupplierName,Aliases\nadd,4,Fine Foods Company,\n")
+	first, err := os.ReadFile(segmentPath(supplierNameFilePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	update("Op,Id,SupplierName,Aliases\ndelete,123,\n")
+	both, err := os.ReadFile(segmentPath(supplierNameFilePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every cut of the second batch, e.g. "delete,12" of "delete,123", leaves the first batch only
+	for cut := len(first); cut < len(both); cut++ {
+		if err := os.WriteFile(segmentPath(supplierNameFilePath), both[:cut], 0644); err != nil {
+			t.Fatal(err)
+		}
+		segment, err := readIndexSegment(supplierNameFilePath)
+		if err != nil {
+			t.Fatalf("readIndexSegment() cut at %d error = %v", cut, err)
+		}
+		if len(segment.deleted) != 0 || segment.size != int64(len(first)) || len(segment.ids) != 1 {
+			t.Fatalf("readIndexSegment() cut at %d = deleted %v, size %d, ids %v, want the first batch only", cut, segment.deleted, segment.size, segment.ids)
+		}
+	}
+
+	// the next update replaces the incomplete batch
+	update("Op,Id,SupplierName,Aliases\ndelete,12,\n")
+	segment, err := readIndexSegment(supplierNameFilePath)
+	if err != nil {
+		t.Fatalf("readIndexSegment() error = %v", err)
+	}
+	if !segment.deleted["12"] || segment.deleted["123"] || len(segment.ids) != 1 {
+		t.Errorf("readIndexSegment() = deleted %v, ids %v, want supplier 12 deleted and supplier 4 added", segment.deleted, segment.ids)
+	}
+}
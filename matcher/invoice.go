@@ -0,0 +1,203 @@
+package matcher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// InvoiceFileError - a malformed record in the invoice file
+type InvoiceFileError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *InvoiceFileError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: invalid invoice text: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// InvoiceFormat - the format of an invoice file
+type InvoiceFormat string
+
+const (
+	InvoiceFormatAuto      InvoiceFormat = "auto"   // detect the format by the content, see detectInvoiceFormat
+	InvoiceFormatPyDict    InvoiceFormat = "pydict" // a Python dict literal of a word per line, see LoadInvoiceFile
+	InvoiceFormatJson      InvoiceFormat = "json"   // a JSON array of words
+	InvoiceFormatJsonLines InvoiceFormat = "jsonl"  // a JSON object of a word per line
+	InvoiceFormatHocr      InvoiceFormat = "hocr"   // an hOCR HTML document
+	InvoiceFormatAlto      InvoiceFormat = "alto"   // an ALTO XML document
+	InvoiceFormatText      InvoiceFormat = "text"   // plain text, see readTextInvoice
+)
+
+// LoadInvoice - load words of an invoice from file in the given format, the zero value detects the format
+func LoadInvoice(invoiceFilePath string, format InvoiceFormat) (words []*Word, err error) {
+	// the word size in an invoice is limited, so the file is read at once
+	content, err := os.ReadFile(invoiceFilePath)
+	if err != nil {
+		return
+	}
+	if format == "" || format == InvoiceFormatAuto {
+		format = detectInvoiceFormat(content)
+	}
+	switch format {
+	case InvoiceFormatPyDict:
+		return readPyDictInvoice(invoiceFilePath, bytes.NewReader(content))
+	case InvoiceFormatJson:
+		return readJsonInvoice(invoiceFilePath, content)
+	case InvoiceFormatJsonLines:
+		return readJsonLinesInvoice(invoiceFilePath, bytes.NewReader(content))
+	case InvoiceFormatHocr:
+		return readHocrInvoice(invoiceFilePath, content)
+	case InvoiceFormatAlto:
+		return readAltoInvoice(invoiceFilePath, content)
+	case InvoiceFormatText:
+		return readTextInvoice(content), nil
+	}
+	return nil, fmt.Errorf("unknown invoice format %q", format)
+}
+
+// detectInvoiceFormat - guess the format of an invoice file by its first line, or its root element for markup
+// a file without the prefix of a structured format is plain text. An array of objects is JSON even if it is invalid,
+// so its syntax error is reported instead of searching its text, but a text starting with "[DRAFT]" is text.
+func detectInvoiceFormat(content []byte) InvoiceFormat {
+	content = bytes.TrimLeft(content, " \t\r\n\f")
+	if bytes.HasPrefix(content, []byte("[")) {
+		if first := bytes.TrimLeft(content[1:], " \t\r\n\f"); len(first) == 0 || first[0] == '{' || first[0] == ']' {
+			return InvoiceFormatJson
+		}
+	}
+	if bytes.HasPrefix(content, []byte("<")) {
+		switch {
+		case bytes.Contains(content, []byte("<alto")):
+			return InvoiceFormatAlto
+		case bytes.Contains(content, []byte("ocr_page")), bytes.Contains(content, []byte("ocrx_word")):
+			return InvoiceFormatHocr
+		}
+	}
+	firstLine := content
+	if i := bytes.IndexByte(content, '\n'); i >= 0 {
+		firstLine = content[:i]
+	}
+	if bytes.HasPrefix(content, []byte("{")) {
+		if json.Valid(firstLine) {
+			return InvoiceFormatJsonLines
+		}
+		return InvoiceFormatPyDict
+	}
+	if len(content) == 0 {
+		return InvoiceFormatPyDict
+	}
+	return InvoiceFormatText
+}
+
+// LoadInvoiceFile - load words of an invoice from file
+// each line is a Python dict literal of a word, e.g. {'pos_id': 0, 'word': 'Demo', 'line_id': 4, 'page_id': 1, ...}
+// the keys can be in any order, word, pos_id, line_id and page_id are required, unknown keys are ignored
+func LoadInvoiceFile(invoiceFilePath string) (words []*Word, err error) {
+	invoiceFile, err := os.Open(invoiceFilePath)
+	if err != nil {
+		return
+	}
+	defer invoiceFile.Close()
+	return readPyDictInvoice(invoiceFilePath, invoiceFile)
+}
+
+// readPyDictInvoice - read the words of an invoice in the Python dict format, see LoadInvoiceFile
+func readPyDictInvoice(invoiceFilePath string, r io.Reader) (words []*Word, err error) {
+	words = make([]*Word, 0)
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		// use a Python literal parser instead of json package because the file content is not valid JSON
+		record, err := parsePyDict(line)
+		var word *Word
+		if err == nil {
+			word, err = wordFromPyDict(record)
+		}
+		if err != nil {
+			var syntaxErr *pySyntaxError
+			if errors.As(err, &syntaxErr) {
+				return nil, &InvoiceFileError{File: invoiceFilePath, Line: lineNum, Column: syntaxErr.Column, Msg: syntaxErr.Msg}
+			}
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
+
+// wordFromPyDict - convert a parsed invoice record to a word
+func wordFromPyDict(record map[string]pyValue) (word *Word, err error) {
+	word = &Word{}
+	text, ok := record["word"]
+	if !ok {
+		return nil, &pySyntaxError{Column: 1, Msg: `missing key "word"`}
+	}
+	if word.Word, ok = text.v.(string); !ok {
+		return nil, &pySyntaxError{Column: text.col, Msg: `"word" is not a string`}
+	}
+	ids := []struct {
+		key      string
+		field    *uint32
+		required bool
+	}{
+		{"pos_id", &word.PosId, true},
+		{"line_id", &word.LineId, true},
+		{"page_id", &word.PageId, true},
+		{"word_id", &word.WordId, false},
+		{"cspan_id", &word.CspanId, false},
+		{"rspan_id", &word.RspanId, false},
+	}
+	for _, id := range ids {
+		value, ok := record[id.key]
+		if !ok {
+			if id.required {
+				return nil, &pySyntaxError{Column: 1, Msg: fmt.Sprintf("missing key %q", id.key)}
+			}
+			continue
+		}
+		i, ok := value.v.(int64)
+		if !ok || i < 0 || i > math.MaxUint32 {
+			return nil, &pySyntaxError{Column: value.col, Msg: fmt.Sprintf("%q is not a valid id", id.key)}
+		}
+		*id.field = uint32(i)
+	}
+	box := []struct {
+		key   string
+		field *float64
+	}{
+		{"left", &word.Left},
+		{"top", &word.Top},
+		{"width", &word.Width},
+		{"height", &word.Height},
+		{"right", &word.Right},
+	}
+	for _, b := range box {
+		value, ok := record[b.key]
+		if !ok {
+			continue
+		}
+		switch v := value.v.(type) {
+		case float64:
+			*b.field = v
+		case int64:
+			*b.field = float64(v)
+		default:
+			return nil, &pySyntaxError{Column: value.col, Msg: fmt.Sprintf("%q is not a number", b.key)}
+		}
+	}
+	return word, nil
+}
@@ -0,0 +1,119 @@
+package matcher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// readJsonInvoice - read the words of an invoice from a JSON array of word objects with the field names of Word
+// the keys of jsonWordKeys are required, a missing one is reported at the object of the word
+func readJsonInvoice(invoiceFilePath string, content []byte) (words []*Word, err error) {
+	words = make([]*Word, 0)
+	if err = json.Unmarshal(content, &words); err != nil {
+		if offset, ok := jsonErrorOffset(err); ok {
+			line, column := lineColumn(content, offset)
+			return nil, &InvoiceFileError{File: invoiceFilePath, Line: line, Column: column, Msg: err.Error()}
+		}
+		return nil, err
+	}
+	for i, word := range words {
+		if word == nil {
+			return nil, &InvoiceFileError{File: invoiceFilePath, Line: 1, Column: 1, Msg: fmt.Sprintf("null word at index %d", i)}
+		}
+	}
+	// the objects are read again with their offset to check their keys, the array is valid
+	dec := json.NewDecoder(bytes.NewReader(content))
+	if _, err = dec.Token(); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		var object json.RawMessage
+		if err = dec.Decode(&object); err != nil {
+			return nil, err
+		}
+		if msg := checkJsonWordKeys(object); msg != "" {
+			line, column := lineColumn(content, dec.InputOffset()-int64(len(object))+1)
+			return nil, &InvoiceFileError{File: invoiceFilePath, Line: line, Column: column, Msg: msg}
+		}
+	}
+	return words, nil
+}
+
+// readJsonLinesInvoice - read the words of an invoice from a JSON object of a word per line
+func readJsonLinesInvoice(invoiceFilePath string, r io.Reader) (words []*Word, err error) {
+	words = make([]*Word, 0)
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var word *Word
+		if err = json.Unmarshal(line, &word); err == nil && word == nil {
+			err = errors.New("null word")
+		}
+		if err != nil {
+			column := 1
+			if offset, ok := jsonErrorOffset(err); ok {
+				_, column = lineColumn(line, offset)
+			}
+			return nil, &InvoiceFileError{File: invoiceFilePath, Line: lineNum, Column: column, Msg: err.Error()}
+		}
+		if msg := checkJsonWordKeys(line); msg != "" {
+			column := len(line) - len(bytes.TrimLeft(line, " \t\r")) + 1
+			return nil, &InvoiceFileError{File: invoiceFilePath, Line: lineNum, Column: column, Msg: msg}
+		}
+		words = append(words, word)
+	}
+	return words, scanner.Err()
+}
+
+// jsonWordKeys - the keys of a word object required like the ones of a word of a pydict invoice, see wordFromPyDict
+var jsonWordKeys = []string{"word", "pos_id", "line_id", "page_id"}
+
+// checkJsonWordKeys - the error message of the first required key missing or null in the JSON object of a word, empty if none
+func checkJsonWordKeys(object []byte) (msg string) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(object, &fields); err != nil {
+		return err.Error()
+	}
+	for _, key := range jsonWordKeys {
+		if value, ok := fields[key]; !ok || string(value) == "null" {
+			return fmt.Sprintf("missing key %q", key)
+		}
+	}
+	return ""
+}
+
+// jsonErrorOffset - the offset in the input where the JSON decoding failed
+func jsonErrorOffset(err error) (offset int64, ok bool) {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return syntaxErr.Offset, true
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return typeErr.Offset, true
+	}
+	return 0, false
+}
+
+// lineColumn - the 1-based line and column of the byte before offset in content
+func lineColumn(content []byte, offset int64) (line, column int) {
+	if offset > int64(len(content)) {
+		offset = int64(len(content))
+	}
+	if offset > 0 {
+		offset-- // the offset is after the byte that failed
+	}
+	before := content[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	column = int(offset) - bytes.LastIndexByte(before, '\n')
+	return
+}
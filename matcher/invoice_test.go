@@ -0,0 +1,401 @@
+package matcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadInvoiceFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []*Word
+	}{
+		{
+			name:    "any key order",
+			content: "{'word': 'Demo', 'page_id': 1, 'line_id': 4, 'pos_id': 0, 'word_id': 31, 'cspan_id': 19, 'rspan_id': 2, 'left': 4, 'top': 13.0, 'width': 4.63, 'height': 1e0, 'right': 8.67}\n",
+			want:    []*Word{{Word: "Demo", PageId: 1, LineId: 4, WordId: 31, CspanId: 19, RspanId: 2, Left: 4, Top: 13, Width: 4.63, Height: 1, Right: 8.67}},
+		},
+		{
+			name:    "escaped quote",
+			content: `{'pos_id': 0, 'word': 'O\'Brien', 'line_id': 0, 'page_id': 1}` + "\n",
+			want:    []*Word{{Word: "O'Brien", PageId: 1}},
+		},
+		{
+			name:    "double quotes",
+			content: `{'pos_id': 0, 'word': "Joe's", 'line_id': 0, 'page_id': 1}` + "\n",
+			want:    []*Word{{Word: "Joe's", PageId: 1}},
+		},
+		{
+			name:    "escapes",
+			content: `{'pos_id': 0, 'word': 'a\\b\x41é\101\q', 'line_id': 0, 'page_id': 1}` + "\n",
+			want:    []*Word{{Word: `a\bAéA\q`, PageId: 1}},
+		},
+		{
+			name:    "separators in word",
+			content: `{'pos_id': 0, 'word': "'line_id': 9, }", 'line_id': 0, 'page_id': 1, 'extra': None, 'flag': True,}` + "\n",
+			want:    []*Word{{Word: "'line_id': 9, }", PageId: 1}},
+		},
+		{
+			name:    "blank lines",
+			content: "\n{'pos_id': 1, 'word': 'A', 'line_id': 2, 'page_id': 3}\n\n",
+			want:    []*Word{{Word: "A", PosId: 1, LineId: 2, PageId: 3}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadInvoiceFile(writeInvoiceFile(t, tt.content))
+			if err != nil {
+				t.Fatalf("LoadInvoiceFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LoadInvoiceFile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadInvoiceFile_error(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		line    int
+		column  int
+	}{
+		{
+			name:    "unterminated string",
+			content: "{'pos_id': 0, 'word': 'A', 'line_id': 0, 'page_id': 1}\n{'pos_id': 0, 'word': 'A}\n",
+			line:    2,
+			column:  23,
+		},
+		{
+			name:    "missing colon",
+			content: "{'pos_id' 0}\n",
+			line:    1,
+			column:  11,
+		},
+		{
+			name:    "invalid value",
+			content: "{'pos_id': zero}\n",
+			line:    1,
+			column:  12,
+		},
+		{
+			name:    "trailing text",
+			content: "{'pos_id': 0} x\n",
+			line:    1,
+			column:  15,
+		},
+		{
+			name:    "missing key",
+			content: "{'pos_id': 0, 'word': 'A', 'line_id': 0}\n",
+			line:    1,
+			column:  1,
+		},
+		{
+			name:    "invalid id",
+			content: "{'pos_id': 1.5, 'word': 'A', 'line_id': 0, 'page_id': 1}\n",
+			line:    1,
+			column:  12,
+		},
+		{
+			name:    "invalid geometry",
+			content: "{'pos_id': 0, 'word': 'A', 'line_id': 0, 'page_id': 1, 'top': '1'}\n",
+			line:    1,
+			column:  63,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeInvoiceFile(t, tt.content)
+			_, err := LoadInvoiceFile(path)
+			var fileErr *InvoiceFileError
+			if !errors.As(err, &fileErr) {
+				t.Fatalf("LoadInvoiceFile() error = %v, want InvoiceFileError", err)
+			}
+			if fileErr.File != path || fileErr.Line != tt.line || fileErr.Column != tt.column {
+				t.Errorf("LoadInvoiceFile() error = %v, want line %d column %d", err, tt.line, tt.column)
+			}
+		})
+	}
+}
+
+func writeInvoiceFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "invoice.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadInvoice(t *testing.T) {
+	want, err := LoadInvoiceFile(testInvoiceFilePath)
+	if err != nil {
+		t.Fatalf("LoadInvoiceFile() error = %v", err)
+	}
+	jsonContent, err := json.MarshalIndent(want, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jsonLinesContent bytes.Buffer
+	for _, w := range want {
+		line, err := json.Marshal(w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jsonLinesContent.Write(line)
+		jsonLinesContent.WriteString("\n")
+	}
+	pyDictContent, err := os.ReadFile(testInvoiceFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		content []byte
+		format  InvoiceFormat
+	}{
+		{name: "pydict", content: pyDictContent, format: InvoiceFormatPyDict},
+		{name: "json", content: jsonContent, format: InvoiceFormatJson},
+		{name: "jsonl", content: jsonLinesContent.Bytes(), format: InvoiceFormatJsonLines},
+		{name: "detect pydict", content: pyDictContent, format: InvoiceFormatAuto},
+		{name: "detect json", content: jsonContent, format: ""},
+		{name: "detect jsonl", content: jsonLinesContent.Bytes(), format: InvoiceFormatAuto},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadInvoice(writeInvoiceFile(t, string(tt.content)), tt.format)
+			if err != nil {
+				t.Fatalf("LoadInvoice() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadInvoice() = %v words, want the %v words of the Python dict format", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestLoadInvoice_error(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  InvoiceFormat
+		line    int
+		column  int
+	}{
+		{
+			name:    "json syntax",
+			content: "[\n  {\"word\": \"Demo\", \"pos_id\": 0},\n  {\"word\": \"Company\" \"pos_id\": 1}\n]\n",
+			format:  InvoiceFormatJson,
+			line:    3,
+			column:  22,
+		},
+		{
+			name:    "json type",
+			content: "[\n  {\"word\": \"Demo\", \"pos_id\": -1}\n]\n",
+			format:  InvoiceFormatJson,
+			line:    2,
+			column:  31,
+		},
+		{
+			name:    "json truncated detected",
+			content: "[\n  {\"word\": \"Demo\", \"pos_id\": 0, \"line_id\": 4, \"page_id\": 1},\n  {\"word\": \"Company\", \"pos_id\": 1, \"line_id\": 4, \"page_id\": 1},\n",
+			format:  InvoiceFormatAuto,
+			line:    3,
+			column:  64,
+		},
+		{
+			name:    "jsonl syntax",
+			content: "{\"word\": \"Demo\", \"pos_id\": 0, \"line_id\": 0, \"page_id\": 1}\n{\"word\": \"Company\", \"pos_id\": 1\n",
+			format:  InvoiceFormatAuto,
+			line:    2,
+			column:  31,
+		},
+		{
+			name:    "jsonl null",
+			content: "{\"word\": \"Demo\", \"pos_id\": 0, \"line_id\": 0, \"page_id\": 1}\nnull\n",
+			format:  InvoiceFormatJsonLines,
+			line:    2,
+			column:  1,
+		},
+		{
+			name:    "json missing id",
+			content: "[\n  {\"word\": \"Demo\", \"pos_id\": 0, \"line_id\": 0, \"page_id\": 1},\n  {\"word\": \"Company\", \"pos_id\": 1, \"line_id\": 0}\n]\n",
+			format:  InvoiceFormatJson,
+			line:    3,
+			column:  3,
+		},
+		{
+			name:    "jsonl null id",
+			content: "{\"word\": \"Demo\", \"pos_id\": 0, \"line_id\": 0, \"page_id\": 1}\n  {\"word\": \"Company\", \"pos_id\": null, \"line_id\": 0, \"page_id\": 1}\n",
+			format:  InvoiceFormatJsonLines,
+			line:    2,
+			column:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeInvoiceFile(t, tt.content)
+			_, err := LoadInvoice(path, tt.format)
+			var fileErr *InvoiceFileError
+			if !errors.As(err, &fileErr) {
+				t.Fatalf("LoadInvoice() error = %v, want InvoiceFileError", err)
+			}
+			if fileErr.File != path || fileErr.Line != tt.line || fileErr.Column != tt.column {
+				t.Errorf("LoadInvoice() error = %v, want line %d column %d", err, tt.line, tt.column)
+			}
+		})
+	}
+	if _, err := LoadInvoice(writeInvoiceFile(t, ""), "csv"); err == nil {
+		t.Errorf("LoadInvoice() error = nil, want unknown invoice format")
+	}
+}
+
+func TestLoadInvoice_markup(t *testing.T) {
+	want := []*Word{
+		{Word: "(PI", PageId: 1, LineId: 0, PosId: 0, Left: 100, Top: 130, Width: 200, Height: 65, Right: 300},
+		{Word: "INVOICE", PageId: 1, LineId: 0, PosId: 1, Left: 1900, Top: 120, Width: 300, Height: 60, Right: 2200},
+		{Word: "Demo", PageId: 1, LineId: 1, PosId: 0, Left: 100, Top: 400, Width: 115, Height: 32, Right: 215},
+		{Word: "Company", PageId: 1, LineId: 1, PosId: 1, Left: 230, Top: 402, Width: 190, Height: 38, Right: 420},
+		{Word: "Invoice", PageId: 1, LineId: 1, PosId: 2, Left: 1700, Top: 400, Width: 180, Height: 32, Right: 1880},
+		{Word: "No:", PageId: 1, LineId: 1, PosId: 3, Left: 1900, Top: 400, Width: 60, Height: 32, Right: 1960},
+		{Word: "INV-0042", PageId: 1, LineId: 1, PosId: 4, Left: 1980, Top: 400, Width: 220, Height: 32, Right: 2200},
+		{Word: "Phone", PageId: 1, LineId: 2, PosId: 0, Left: 100, Top: 460, Width: 150, Height: 32, Right: 250},
+		{Word: ":", PageId: 1, LineId: 2, PosId: 1, Left: 262, Top: 460, Width: 13, Height: 32, Right: 275},
+		{Word: "111.222.3333", PageId: 1, LineId: 2, PosId: 2, Left: 290, Top: 460, Width: 270, Height: 32, Right: 560},
+	}
+	tests := []struct {
+		name   string
+		path   string
+		format InvoiceFormat
+	}{
+		{name: "hocr", path: testHocrInvoiceFilePath, format: InvoiceFormatHocr},
+		{name: "alto", path: testAltoInvoiceFilePath, format: InvoiceFormatAlto},
+		{name: "detect hocr", path: testHocrInvoiceFilePath, format: InvoiceFormatAuto},
+		{name: "detect alto", path: testAltoInvoiceFilePath, format: InvoiceFormatAuto},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadInvoice(tt.path, tt.format)
+			if err != nil {
+				t.Fatalf("LoadInvoice() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("LoadInvoice() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadInvoice_markupError(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		format  InvoiceFormat
+		line    int
+	}{
+		{
+			name:    "invalid bbox",
+			content: "<html><body>\n<div class='ocr_page'>\n<span class='ocrx_word' title='bbox 1 2 3'>Demo</span>\n</div></body></html>\n",
+			format:  InvoiceFormatHocr,
+			line:    3,
+		},
+		{
+			name:    "invalid position",
+			content: "<alto><Layout><Page>\n<TextLine><String CONTENT='Demo' HPOS='x'/></TextLine>\n</Page></Layout></alto>\n",
+			format:  InvoiceFormatAlto,
+			line:    2,
+		},
+		{
+			name:    "unclosed attribute",
+			content: "<alto><Layout><Page>\n<TextLine>\n<String CONTENT='Demo/>\n",
+			format:  InvoiceFormatAlto,
+			line:    3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeInvoiceFile(t, tt.content)
+			_, err := LoadInvoice(path, tt.format)
+			var fileErr *InvoiceFileError
+			if !errors.As(err, &fileErr) {
+				t.Fatalf("LoadInvoice() error = %v, want InvoiceFileError", err)
+			}
+			if fileErr.File != path || fileErr.Line != tt.line {
+				t.Errorf("LoadInvoice() error = %v, want line %d", err, tt.line)
+			}
+		})
+	}
+}
+
+func TestFindSupplierNameV2_markup(t *testing.T) {
+	supplierNameFilePath := copySupplierNameFile(t)
+	if _, err := BuildIndex(supplierNameFilePath, DefaultOptions()); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	opts := DefaultOptions()
+	opts.Geometry = true
+	for _, path := range []string{testHocrInvoiceFilePath, testAltoInvoiceFilePath} {
+		got, _, err := FindSupplierNameV2(context.Background(), path, supplierNameFilePath, opts)
+		if err != nil {
+			t.Fatalf("FindSupplierNameV2(%s) error = %v", path, err)
+		}
+		if got.SupplierId != "3153303" || got.SupplierName != "Demo Company" {
+			t.Errorf("FindSupplierNameV2(%s) = %v, want 3153303,Demo Company", path, got.Supplier())
+		}
+	}
+}
+
+func TestLoadInvoice_text(t *testing.T) {
+	content := "INVOICE  No: 42\r\n\nDemo\tCompany\n\f\fPhone : 111.222.3333\n"
+	want := []*Word{
+		{Word: "INVOICE", PageId: 1, LineId: 0, PosId: 0},
+		{Word: "No:", PageId: 1, LineId: 0, PosId: 1},
+		{Word: "42", PageId: 1, LineId: 0, PosId: 2},
+		{Word: "Demo", PageId: 1, LineId: 2, PosId: 0},
+		{Word: "Company", PageId: 1, LineId: 2, PosId: 1},
+		{Word: "Phone", PageId: 3, LineId: 0, PosId: 0},
+		{Word: ":", PageId: 3, LineId: 0, PosId: 1},
+		{Word: "111.222.3333", PageId: 3, LineId: 0, PosId: 2},
+	}
+	for _, format := range []InvoiceFormat{InvoiceFormatText, InvoiceFormatAuto} {
+		got, err := LoadInvoice(writeInvoiceFile(t, content), format)
+		if err != nil {
+			t.Fatalf("LoadInvoice(%s) error = %v", format, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("LoadInvoice(%s) = %+v, want %+v", format, got, want)
+		}
+	}
+}
+
+func TestDetectInvoiceFormat(t *testing.T) {
+	tests := []struct {
+		content string
+		want    InvoiceFormat
+	}{
+		{content: "{'pos_id': 0, 'word': 'Demo'}\n", want: InvoiceFormatPyDict},
+		{content: "{\"pos_id\": 0, \"word\": \"Demo\"}\n", want: InvoiceFormatJsonLines},
+		{content: " [{\"pos_id\": 0, \"word\": \"Demo\"}]", want: InvoiceFormatJson},
+		{content: "<html><div class='ocr_page'></div></html>", want: InvoiceFormatHocr},
+		{content: "<?xml version=\"1.0\"?>\n<alto></alto>", want: InvoiceFormatAlto},
+		{content: "2024\nDemo Company\n", want: InvoiceFormatText},
+		{content: "[DRAFT] Demo Company\n", want: InvoiceFormatText},
+		{content: "[\n  {\"word\": \"Demo\", \"pos_id\": 0, \"line_id\": 4, \"page_id\": 1},\n  {\"word\": \"Company\", \"pos_id\": 1, \"line_id\": 4, \"page_id\": 1},\n", want: InvoiceFormatJson},
+		{content: "[]", want: InvoiceFormatJson},
+		{content: "<Demo Company>\n", want: InvoiceFormatText},
+		{content: "", want: InvoiceFormatPyDict},
+	}
+	for _, tt := range tests {
+		if got := detectInvoiceFormat([]byte(tt.content)); got != tt.want {
+			t.Errorf("detectInvoiceFormat(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package matcher
+
+import (
+	"bytes"
+	"strings"
+)
+
+// readTextInvoice - read the words of a plain text invoice, e.g. the output of pdftotext or an email body
+// form feeds separate the pages starting at 1, newlines separate the lines starting at 0 on every page
+// and whitespace separates the words starting at 0 on every line. Blank lines are counted, the words have no bounding box.
+func readTextInvoice(content []byte) (words []*Word) {
+	words = make([]*Word, 0)
+	for pageIdx, page := range bytes.Split(content, []byte("\f")) {
+		for lineIdx, line := range strings.Split(string(page), "\n") {
+			for posIdx, word := range strings.Fields(line) {
+				words = append(words, &Word{
+					Word:   word,
+					PageId: uint32(pageIdx + 1),
+					LineId: uint32(lineIdx),
+					PosId:  uint32(posIdx),
+				})
+			}
+		}
+	}
+	return
+}
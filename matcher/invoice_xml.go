@@ -0,0 +1,219 @@
+package matcher
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// wordBuilder - number the words read from a document in reading order
+// pages start at 1 like invoice.txt, lines start at 0 on every page and positions start at 0 on every line
+type wordBuilder struct {
+	words  []*Word
+	pageId uint32
+	lineId uint32
+	posId  uint32
+	inLine bool
+}
+
+func (b *wordBuilder) startPage() {
+	b.pageId++
+	b.lineId = 0
+	b.posId = 0
+	b.inLine = false
+}
+
+func (b *wordBuilder) startLine() {
+	if b.inLine {
+		b.lineId++
+	}
+	b.posId = 0
+	b.inLine = true
+}
+
+// add - add a word at the current position, empty words are skipped
+func (b *wordBuilder) add(word *Word) {
+	word.Word = strings.TrimSpace(word.Word)
+	if word.Word == "" {
+		return
+	}
+	if b.pageId == 0 { // no page element before the word
+		b.startPage()
+	}
+	if !b.inLine { // no line element before the word
+		b.startLine()
+	}
+	word.PageId = b.pageId
+	word.LineId = b.lineId
+	word.PosId = b.posId
+	b.posId++
+	b.words = append(b.words, word)
+}
+
+// readHocrInvoice - read the words of an hOCR document, e.g. tesseract invoice.png invoice hocr
+// the words are the ocrx_word elements and their bounding box is the bbox property of their title
+func readHocrInvoice(invoiceFilePath string, content []byte) (words []*Word, err error) {
+	decoder := newXmlDecoder(content)
+	b := &wordBuilder{words: make([]*Word, 0)}
+	var word *Word
+	wordDepth, depth := 0, 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, xmlInvoiceError(invoiceFilePath, content, decoder, err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			depth++
+			if word != nil { // markup inside the word such as <strong>
+				continue
+			}
+			classes := strings.Fields(xmlAttr(t, "class"))
+			switch {
+			case hasString(classes, "ocr_page"):
+				b.startPage()
+			case hasString(classes, "ocr_line"), hasString(classes, "ocrx_line"), hasString(classes, "ocr_header"),
+				hasString(classes, "ocr_caption"), hasString(classes, "ocr_textfloat"):
+				b.startLine()
+			case hasString(classes, "ocrx_word"):
+				word = &Word{}
+				wordDepth = depth
+				if err := parseHocrBbox(xmlAttr(t, "title"), word); err != nil {
+					return nil, xmlInvoiceError(invoiceFilePath, content, decoder, err)
+				}
+			}
+		case xml.CharData:
+			if word != nil {
+				word.Word += string(t)
+			}
+		case xml.EndElement:
+			if word != nil && depth == wordDepth {
+				b.add(word)
+				word = nil
+			}
+			depth--
+		}
+	}
+	return b.words, nil
+}
+
+// parseHocrBbox - set the bounding box of the word from an hOCR title such as "bbox 10 20 30 40; x_wconf 95"
+func parseHocrBbox(title string, word *Word) error {
+	for _, property := range strings.Split(title, ";") {
+		fields := strings.Fields(property)
+		if len(fields) == 0 || fields[0] != "bbox" {
+			continue
+		}
+		if len(fields) != 5 {
+			return fmt.Errorf("invalid bbox %q", strings.TrimSpace(property))
+		}
+		var box [4]float64
+		for i := range box {
+			v, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return fmt.Errorf("invalid bbox %q", strings.TrimSpace(property))
+			}
+			box[i] = v
+		}
+		word.Left, word.Top, word.Right = box[0], box[1], box[2]
+		word.Width, word.Height = box[2]-box[0], box[3]-box[1]
+	}
+	return nil
+}
+
+// readAltoInvoice - read the words of an ALTO XML document, e.g. an ABBYY export
+// the words are the String elements and their bounding box is their HPOS, VPOS, WIDTH and HEIGHT
+func readAltoInvoice(invoiceFilePath string, content []byte) (words []*Word, err error) {
+	decoder := newXmlDecoder(content)
+	b := &wordBuilder{words: make([]*Word, 0)}
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, xmlInvoiceError(invoiceFilePath, content, decoder, err)
+		}
+		t, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch t.Name.Local {
+		case "Page":
+			b.startPage()
+		case "TextLine":
+			b.startLine()
+		case "String":
+			word := &Word{Word: xmlAttr(t, "CONTENT")}
+			box := []struct {
+				attr  string
+				field *float64
+			}{
+				{"HPOS", &word.Left},
+				{"VPOS", &word.Top},
+				{"WIDTH", &word.Width},
+				{"HEIGHT", &word.Height},
+			}
+			for _, a := range box {
+				value := xmlAttr(t, a.attr)
+				if value == "" {
+					continue
+				}
+				v, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					return nil, xmlInvoiceError(invoiceFilePath, content, decoder, fmt.Errorf("invalid %s %q", a.attr, value))
+				}
+				*a.field = v
+			}
+			word.Right = word.Left + word.Width
+			b.add(word)
+		}
+	}
+	return b.words, nil
+}
+
+// newXmlDecoder - a decoder tolerant of the HTML in hOCR documents
+func newXmlDecoder(content []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+	decoder.AutoClose = xml.HTMLAutoClose
+	decoder.Entity = xml.HTMLEntity
+	return decoder
+}
+
+// xmlInvoiceError - report the error at the current position of the decoder
+func xmlInvoiceError(invoiceFilePath string, content []byte, decoder *xml.Decoder, err error) error {
+	msg := err.Error()
+	var syntaxErr *xml.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		msg = syntaxErr.Msg
+	}
+	line, column := lineColumn(content, decoder.InputOffset())
+	return &InvoiceFileError{File: invoiceFilePath, Line: line, Column: column, Msg: msg}
+}
+
+// xmlAttr - the value of the attribute of the element by its local name, empty if missing
+func xmlAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+func hasString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package matcher
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// wordRun - adjacent words on the same line of a page, page.Words[start:end]
+type wordRun struct {
+	start, end int
+}
+
+// tokenSpan - a key made of the supplier name tokens [0, end) that the invoice words can match
+type tokenSpan struct {
+	end    int
+	key    string
+	joined bool // whether the key is matched by a run of adjacent words instead of a single word
+}
+
+// tokenSpans - the keys of the invoice words matching the tokens from the first one
+// a single word can match several tokens joined by space, e.g. "Demo-Company" when punctuation is split,
+// or without space if merged words are matched, e.g. "DemoCompany".
+// A run of adjacent words split by OCR can match several tokens without space if words are joined, e.g. "Dem" "o".
+func (page *Page) tokenSpans(supplierNameToken []string) (spans []tokenSpan) {
+	spans = make([]tokenSpan, 0, len(supplierNameToken))
+	for end := 1; end <= len(supplierNameToken); end++ {
+		spans = append(spans, tokenSpan{end: end, key: strings.Join(supplierNameToken[:end], " ")})
+		merged := strings.Join(supplierNameToken[:end], "")
+		if page.matchMerged && end > 1 {
+			spans = append(spans, tokenSpan{end: end, key: merged})
+		}
+		if page.maxJoin > 1 {
+			spans = append(spans, tokenSpan{end: end, key: merged, joined: true})
+		}
+	}
+	return spans
+}
+
+// joinedKey - the keys of the n words from start concatenated without space
+// ok is false if the words are not adjacent on the same line
+func (page *Page) joinedKey(start, n int) (key string, ok bool) {
+	if start+n > len(page.Words) {
+		return "", false
+	}
+	var b strings.Builder
+	for i := start; i < start+n; i++ {
+		if i > start {
+			prev, cur := page.Words[i-1], page.Words[i]
+			if prev.LineId != cur.LineId || prev.PosId+1 != cur.PosId {
+				return "", false
+			}
+		}
+		b.WriteString(strings.ReplaceAll(page.key(i), " ", ""))
+	}
+	return b.String(), true
+}
+
+// buildJoinMapInPage - map the concatenated keys of 2 to maxJoin adjacent words to their runs in the sorted page
+func buildJoinMapInPage(page *Page, maxJoin int) {
+	if page == nil || maxJoin < 2 {
+		return
+	}
+	page.maxJoin = maxJoin
+	page.joinMap = make(map[string][]wordRun)
+	for start := range page.Words {
+		for n := 2; n <= maxJoin; n++ {
+			key, ok := page.joinedKey(start, n)
+			if !ok {
+				break
+			}
+			page.joinMap[key] = append(page.joinMap[key], wordRun{start: start, end: start + n})
+		}
+	}
+}
+
+// matchTokenSpan - match the words from idxWord of the page with the tokens from idxName
+// return the index after the last matched token, the number of words matching them and the distance,
+// or idxName if the words can not match
+func matchTokenSpan(supplierNameToken []string, idxName int, page *Page, idxWord int) (end int, count int, distance float64) {
+	for _, span := range page.tokenSpans(supplierNameToken[idxName:]) {
+		if span.joined {
+			continue
+		}
+		if distance, ok := page.matchKey(page.key(idxWord), span.key); ok {
+			return idxName + span.end, 1, distance
+		}
+	}
+	for count = 2; count <= page.maxJoin; count++ {
+		wordKey, ok := page.joinedKey(idxWord, count)
+		if !ok {
+			break
+		}
+		for _, span := range page.tokenSpans(supplierNameToken[idxName:]) {
+			if !span.joined {
+				continue
+			}
+			if distance, ok := page.matchKey(wordKey, span.key); ok {
+				return idxName + span.end, count, distance
+			}
+		}
+	}
+	return idxName, 0, 0
+}
+
+// searchRuns - use binary search to find the first run starting at or after idxWord
+func searchRuns(runs []wordRun, idxWord int) int {
+	return sort.Search(len(runs), func(i int) bool {
+		return runs[i].start >= idxWord
+	})
+}
+
+// indexLookupKeys - the keys looked up in the index for the word at idxWord
+// the tokens of the word, and if words can be merged or joined, the substrings of the merged and joined keys,
+// since any token of a supplier name can be in them, e.g. "Demo" and "Company" of "DemoCompany"
+func (page *Page) indexLookupKeys(idxWord int) (keys []string) {
+	key := page.key(idxWord)
+	keys = strings.Fields(key)
+	merged := make([]string, 0)
+	if page.matchMerged {
+		merged = append(merged, strings.ReplaceAll(key, " ", ""))
+	}
+	for n := 2; n <= page.maxJoin; n++ {
+		joined, ok := page.joinedKey(idxWord, n)
+		if !ok {
+			break
+		}
+		merged = append(merged, joined)
+	}
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		seen[k] = true
+	}
+	for _, m := range merged {
+		for i := range m {
+			for j := i + 1; j <= len(m); j++ {
+				if substr := m[i:j]; utf8.ValidString(substr) && !seen[substr] {
+					seen[substr] = true
+					keys = append(keys, substr)
+				}
+			}
+		}
+	}
+	return keys
+}
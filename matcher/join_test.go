@@ -0,0 +1,84 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenSpan_search(t *testing.T) {
+	supplier := &Supplier{Id: "123", SupplierName: "Demo Company"}
+	joinOpts := Options{Normalizer: DefaultNormalizer(), MatchMerged: true, MaxJoin: 3}
+	tests := []struct {
+		name      string
+		opts      Options
+		words     []*Word
+		wantWords []string
+	}{
+		{
+			name:      "merged",
+			opts:      joinOpts,
+			words:     []*Word{{Word: "DemoCompany", LineId: 4, PosId: 0}},
+			wantWords: []string{"DemoCompany"},
+		},
+		{
+			name:      "split",
+			opts:      joinOpts,
+			words:     []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Com", LineId: 4, PosId: 1}, {Word: "pany", LineId: 4, PosId: 2}},
+			wantWords: []string{"Demo", "Com", "pany"},
+		},
+		{
+			name:      "split and merged",
+			opts:      joinOpts,
+			words:     []*Word{{Word: "Dem", LineId: 4, PosId: 0}, {Word: "o", LineId: 4, PosId: 1}, {Word: "Company", LineId: 4, PosId: 2}},
+			wantWords: []string{"Dem", "o", "Company"},
+		},
+		{
+			name:      "split across lines",
+			opts:      joinOpts,
+			words:     []*Word{{Word: "Dem", LineId: 4, PosId: 0}, {Word: "o", LineId: 5, PosId: 0}, {Word: "Company", LineId: 5, PosId: 1}},
+			wantWords: nil,
+		},
+		{
+			name:      "merged disabled",
+			opts:      Options{Normalizer: DefaultNormalizer()},
+			words:     []*Word{{Word: "DemoCompany", LineId: 4, PosId: 0}},
+			wantWords: nil,
+		},
+		{
+			name:      "split disabled",
+			opts:      Options{Normalizer: DefaultNormalizer()},
+			words:     []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Com", LineId: 4, PosId: 1}, {Word: "pany", LineId: 4, PosId: 2}},
+			wantWords: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := NewPages(tt.words, tt.opts)
+			results := []*MatchResult{
+				SearchSupplierFromPage(pages, supplier),
+				SearchSupplierFromPageV2(pages, supplier),
+				SearchSupplierFromPageV3([]*SuppliersForPage{{Page: pages[0], Suppliers: []*Supplier{supplier}}}),
+			}
+			for i, result := range results {
+				var gotWords []string
+				if result != nil {
+					for _, w := range result.Words {
+						gotWords = append(gotWords, w.Word)
+					}
+				}
+				if !reflect.DeepEqual(gotWords, tt.wantWords) {
+					t.Errorf("search #%d words = %v, want %v", i+1, gotWords, tt.wantWords)
+				}
+			}
+		})
+	}
+}
+
+func TestPage_indexLookupKeys(t *testing.T) {
+	words := []*Word{{Word: "Dem", LineId: 4, PosId: 0}, {Word: "oCo", LineId: 4, PosId: 1}}
+	pages := NewPages(words, Options{Normalizer: DefaultNormalizer(), MatchMerged: true, MaxJoin: 2})
+	want := []string{"dem", "d", "de", "e", "em", "m", "demo", "democ", "democo", "emo", "emoc", "emoco", "mo", "moc", "moco", "o", "oc", "oco", "c", "co"}
+	if got := pages[0].indexLookupKeys(0); !reflect.DeepEqual(got, want) {
+		t.Errorf("indexLookupKeys() = %v, want %v", got, want)
+	}
+}
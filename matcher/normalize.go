@@ -0,0 +1,94 @@
+package matcher
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalizer - normalize the invoice words, the supplier name tokens and the index keys the same way before comparing them
+// the zero value keeps the exact matching, the supplier names are split by space and compared byte by byte
+type Normalizer struct {
+	// FoldCase - compare case-insensitively, e.g. "DEMO" matches "Demo"
+	FoldCase bool
+	// NFKC - apply Unicode NFKC normalization, e.g. full width letters and ligatures match their plain form
+	NFKC bool
+	// TrimPunct - strip the punctuation surrounding a token, e.g. "Company," matches "Company"
+	TrimPunct bool
+	// SplitPunct - split a word at the punctuation inside it, e.g. "Demo-Company" matches "Demo Company"
+	SplitPunct bool
+	// Equivalents - replace the equivalent tokens such as "Limited" and "Ltd" by the same token, nil keeps every token
+	Equivalents *Equivalents
+	// OptionalArticle - a leading "The" of a supplier name is optional, which invoices often omit,
+	// e.g. "Fine Foods Company" matches "The Fine Foods Company"
+	OptionalArticle bool
+}
+
+// DefaultNormalizer - the normalizer ignoring case, Unicode compatibility forms and punctuation
+func DefaultNormalizer() Normalizer {
+	return Normalizer{
+		FoldCase:        true,
+		NFKC:            true,
+		TrimPunct:       true,
+		SplitPunct:      true,
+		OptionalArticle: true,
+	}
+}
+
+// leadingArticle - the article optional at the start of a supplier name, see Normalizer.OptionalArticle
+const leadingArticle = "the"
+
+// trimArticle - the tokens without the leading article if it is optional, nil if there is none or nothing else
+func (n Normalizer) trimArticle(tokens []string) []string {
+	if !n.OptionalArticle || len(tokens) < 2 || !strings.EqualFold(tokens[0], leadingArticle) {
+		return nil
+	}
+	return tokens[1:]
+}
+
+// IsExact - whether the normalizer keeps the exact matching
+func (n Normalizer) IsExact() bool {
+	return n == Normalizer{}
+}
+
+// Tokens - split the text into normalized tokens, the whitespace between tokens is collapsed
+func (n Normalizer) Tokens(s string) []string {
+	if n.IsExact() {
+		return strings.Split(s, " ")
+	}
+	if n.NFKC {
+		s = norm.NFKC.String(s)
+	}
+	if n.FoldCase {
+		s = cases.Fold().String(s) // a Caser is not safe for concurrent use
+	}
+	if n.Equivalents != nil {
+		s = n.Equivalents.replaceSymbols(s)
+	}
+	tokens := strings.FieldsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || n.SplitPunct && unicode.IsPunct(r)
+	})
+	normalized := tokens[:0]
+	for _, token := range tokens {
+		if n.TrimPunct {
+			token = strings.TrimFunc(token, unicode.IsPunct)
+		}
+		if n.Equivalents != nil && token != "" {
+			token = n.Equivalents.replace(token, n.FoldCase && n.NFKC)
+		}
+		if token != "" {
+			normalized = append(normalized, token)
+		}
+	}
+	return normalized
+}
+
+// Key - the normalized text used to compare and index an invoice word, the tokens of the word joined by space
+func (n Normalizer) Key(s string) string {
+	if n.IsExact() {
+		return s
+	}
+	return strings.Join(n.Tokens(s), " ")
+}
@@ -0,0 +1,121 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizer_Tokens(t *testing.T) {
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		text       string
+		want       []string
+	}{
+		{
+			name:       "exact",
+			normalizer: Normalizer{},
+			text:       "Demo  Company,",
+			want:       []string{"Demo", "", "Company,"},
+		},
+		{
+			name:       "fold case",
+			normalizer: Normalizer{FoldCase: true},
+			text:       "DEMO Company",
+			want:       []string{"demo", "company"},
+		},
+		{
+			name:       "collapse whitespace",
+			normalizer: Normalizer{FoldCase: true},
+			text:       " Demo \t Company ",
+			want:       []string{"demo", "company"},
+		},
+		{
+			name:       "nfkc",
+			normalizer: Normalizer{NFKC: true},
+			text:       "Ｄｅｍｏ Company",
+			want:       []string{"Demo", "Company"},
+		},
+		{
+			name:       "trim punctuation",
+			normalizer: Normalizer{TrimPunct: true},
+			text:       "(Demo) Company, & Co.",
+			want:       []string{"Demo", "Company", "Co"},
+		},
+		{
+			name:       "split punctuation",
+			normalizer: Normalizer{SplitPunct: true},
+			text:       "Demo-Company",
+			want:       []string{"Demo", "Company"},
+		},
+		{
+			name:       "default",
+			normalizer: DefaultNormalizer(),
+			text:       "JACKS MACHINERY (1992) LTD",
+			want:       []string{"jacks", "machinery", "1992", "ltd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.normalizer.Tokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Tokens() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizer_search(t *testing.T) {
+	supplier := &Supplier{Id: "123", SupplierName: "Demo Company"}
+	tests := []struct {
+		name       string
+		normalizer Normalizer
+		words      []*Word
+		wantMatch  bool
+	}{
+		{
+			name:       "upper case",
+			normalizer: DefaultNormalizer(),
+			words:      []*Word{{Word: "DEMO", LineId: 4, PosId: 0}, {Word: "COMPANY", LineId: 4, PosId: 1}},
+			wantMatch:  true,
+		},
+		{
+			name:       "trailing punctuation",
+			normalizer: DefaultNormalizer(),
+			words:      []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Company,", LineId: 4, PosId: 1}},
+			wantMatch:  true,
+		},
+		{
+			name:       "hyphenated",
+			normalizer: DefaultNormalizer(),
+			words:      []*Word{{Word: "Demo-Company", LineId: 4, PosId: 0}},
+			wantMatch:  true,
+		},
+		{
+			name:       "exact upper case",
+			normalizer: Normalizer{},
+			words:      []*Word{{Word: "DEMO", LineId: 4, PosId: 0}, {Word: "COMPANY", LineId: 4, PosId: 1}},
+			wantMatch:  false,
+		},
+		{
+			name:       "exact hyphenated",
+			normalizer: Normalizer{},
+			words:      []*Word{{Word: "Demo-Company", LineId: 4, PosId: 0}},
+			wantMatch:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pages := NewPages(tt.words, Options{Normalizer: tt.normalizer})
+			if got := SearchSupplierFromPage(pages, supplier) != nil; got != tt.wantMatch {
+				t.Errorf("SearchSupplierFromPage() match = %v, want %v", got, tt.wantMatch)
+			}
+			if got := SearchSupplierFromPageV2(pages, supplier) != nil; got != tt.wantMatch {
+				t.Errorf("SearchSupplierFromPageV2() match = %v, want %v", got, tt.wantMatch)
+			}
+			potential := []*SuppliersForPage{{Page: pages[0], Suppliers: []*Supplier{supplier}}}
+			if got := SearchSupplierFromPageV3(potential) != nil; got != tt.wantMatch {
+				t.Errorf("SearchSupplierFromPageV3() match = %v, want %v", got, tt.wantMatch)
+			}
+		})
+	}
+}
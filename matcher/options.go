@@ -0,0 +1,85 @@
+package matcher
+
+import "fmt"
+
+// Options - options of the supplier name search and the index build
+type Options struct {
+	// WorkerNum - number of workers matching the supplier names, must be greater than 0
+	WorkerNum uint64
+	// InvoiceFormat - the format of the invoice file, the zero value detects the format by the content
+	InvoiceFormat InvoiceFormat
+	// Lenient - skip malformed records in the supplier name file instead of returning an error,
+	// BuildIndex returns the number of records skipped
+	Lenient bool
+	// IdColumn, NameColumn - the headers of the supplier id and name columns in the supplier name file,
+	// DefaultIdColumn and DefaultNameColumn if empty. Without a header the id and the name are the first two columns.
+	IdColumn   string
+	NameColumn string
+	// AliasColumn - the header of the optional column listing the aliases of the supplier separated by "|",
+	// DefaultAliasColumn if empty. The result of a supplier matched by an alias reports the alias and its canonical name.
+	AliasColumn string
+	// Normalizer - normalize the invoice words, the supplier names and the index keys, the zero value matches exactly.
+	// The index must be searched with the normalizer it was built with.
+	Normalizer Normalizer
+	// MaxDistance - the max edit distance of an invoice word matching a supplier name token, 0 means exact.
+	// OCR confusions such as 0/O, 1/l/I and rn/m cost less than other edits, see ocrDistance.
+	MaxDistance float64
+	// MatchMerged - a word can match several tokens written without space, e.g. "DemoCompany" matches "Demo Company"
+	MatchMerged bool
+	// MaxJoin - the max number of adjacent words on a line joined to match tokens split by OCR,
+	// e.g. "Dem" "o" matches "Demo" with 2, 0 or 1 never joins words
+	MaxJoin int
+	// Geometry - consecutive words of a supplier name must be spatially close by their bounding box,
+	// on the same baseline or directly below, instead of at most one line apart, see isNear
+	Geometry bool
+	// OptionalSuffix - a supplier name matches without the legal suffixes at its end, e.g. "Demo Pty Ltd" matches "Demo".
+	// The legal suffixes are the ones of Normalizer.Equivalents.
+	OptionalSuffix bool
+	// MatchIdentifiers - match the tax numbers, phone numbers, emails and domains of the suppliers in the invoice.
+	// An identifier found boosts the score of a supplier whose name matched, or matches the supplier by itself.
+	MatchIdentifiers bool
+	// IdentifierColumns - the headers of the identifier columns of the supplier name file and their type,
+	// DefaultIdentifierColumns if nil. A column can list several identifiers separated by "|".
+	IdentifierColumns map[string]IdentifierType
+	// MinTokenFraction - the min fraction of the distinct tokens of a supplier name found in a page to search it
+	// with the index, at least one token is always required, e.g. 0.5 searches "Demo Company" if only "Company" is in the page
+	MinTokenFraction float64
+	// RebuildStaleIndex - build the index files again when searching with a stale index instead of returning
+	// ErrStaleIndex, e.g. after the supplier name file is edited
+	RebuildStaleIndex bool
+	// IndexMemoryBudget - the memory in bytes of the postings buffered while building the index, once exceeded they are
+	// sorted and spilled to temporary files next to the index files, which are merged at the end, 0 means no limit
+	IndexMemoryBudget int64
+	// TopK - the number of ranked results returned when searching all matches, 0 means all of them
+	TopK int
+}
+
+// DefaultMinTokenFraction - the fraction of the tokens of a supplier name required in a page by default
+const DefaultMinTokenFraction = 0.5
+
+// DefaultIndexMemoryBudget - the memory of the postings buffered while building the index by default
+const DefaultIndexMemoryBudget = 64 << 20
+
+// DefaultOptions - the options used by the CLI by default
+func DefaultOptions() Options {
+	return Options{
+		WorkerNum:         5,
+		IdColumn:          DefaultIdColumn,
+		NameColumn:        DefaultNameColumn,
+		AliasColumn:       DefaultAliasColumn,
+		Normalizer:        DefaultNormalizer(),
+		MinTokenFraction:  DefaultMinTokenFraction,
+		IndexMemoryBudget: DefaultIndexMemoryBudget,
+	}
+}
+
+// validate - check the options before searching
+func (o Options) validate() error {
+	if o.WorkerNum == 0 {
+		return fmt.Errorf("invalid worker num")
+	}
+	if o.MinTokenFraction < 0 || o.MinTokenFraction > 1 {
+		return fmt.Errorf("invalid min token fraction %v", o.MinTokenFraction)
+	}
+	return nil
+}
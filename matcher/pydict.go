@@ -0,0 +1,260 @@
+package matcher
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// pySyntaxError - a syntax error at the 1-based column of a Python literal
+type pySyntaxError struct {
+	Column int
+	Msg    string
+}
+
+func (e *pySyntaxError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Msg)
+}
+
+// pyValue - a value of a Python dict literal and the 1-based column it starts at
+// v is a string, int64, float64, bool or nil
+type pyValue struct {
+	v   interface{}
+	col int
+}
+
+// parsePyDict - parse a Python dict literal as printed by repr, e.g. {'word': 'Demo', 'pos_id': 0, 'top': 13.0}
+// keys must be strings, values can be strings with single or double quotes and escapes, ints, floats, True, False or None
+func parsePyDict(s string) (dict map[string]pyValue, err error) {
+	p := &pyParser{s: s}
+	dict = make(map[string]pyValue)
+	p.skipSpace()
+	if err = p.expect('{'); err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+	} else {
+		for {
+			p.skipSpace()
+			if c := p.peek(); c != '\'' && c != '"' {
+				return nil, p.errorf("expected string key, found %s", p.found())
+			}
+			key, err := p.parseString()
+			if err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			if err = p.expect(':'); err != nil {
+				return nil, err
+			}
+			p.skipSpace()
+			col := p.pos + 1
+			v, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			dict[key] = pyValue{v: v, col: col}
+			p.skipSpace()
+			if p.peek() == ',' {
+				p.pos++
+				p.skipSpace()
+				if p.peek() == '}' { // trailing comma
+					p.pos++
+					break
+				}
+				continue
+			}
+			if err = p.expect('}'); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	p.skipSpace()
+	if p.pos < len(p.s) {
+		return nil, p.errorf("unexpected %s after dict", p.found())
+	}
+	return dict, nil
+}
+
+// pyParser - a recursive descent parser of a Python literal
+type pyParser struct {
+	s   string
+	pos int
+}
+
+// peek - the next byte, 0 at the end
+func (p *pyParser) peek() byte {
+	if p.pos >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+// found - describe the next character for error messages
+func (p *pyParser) found() string {
+	if p.pos >= len(p.s) {
+		return "end of line"
+	}
+	r, _ := utf8.DecodeRuneInString(p.s[p.pos:])
+	return strconv.QuoteRune(r)
+}
+
+func (p *pyParser) errorf(format string, args ...interface{}) error {
+	return &pySyntaxError{Column: p.pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *pyParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t' || p.s[p.pos] == '\r') {
+		p.pos++
+	}
+}
+
+func (p *pyParser) expect(c byte) error {
+	if p.peek() != c {
+		return p.errorf("expected %q, found %s", c, p.found())
+	}
+	p.pos++
+	return nil
+}
+
+func (p *pyParser) parseValue() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		return p.parseString()
+	case c == '-' || c == '+' || c == '.' || c >= '0' && c <= '9':
+		return p.parseNumber()
+	}
+	for _, name := range []string{"True", "False", "None"} {
+		if strings.HasPrefix(p.s[p.pos:], name) {
+			p.pos += len(name)
+			switch name {
+			case "True":
+				return true, nil
+			case "False":
+				return false, nil
+			}
+			return nil, nil
+		}
+	}
+	return nil, p.errorf("expected value, found %s", p.found())
+}
+
+// parseNumber - parse an int or a float such as -1, 3.0, .5 or 1e-3
+func (p *pyParser) parseNumber() (interface{}, error) {
+	start := p.pos
+	isFloat := false
+	if c := p.peek(); c == '-' || c == '+' {
+		p.pos++
+	}
+	digits := p.skipDigits()
+	if p.peek() == '.' {
+		isFloat = true
+		p.pos++
+		digits += p.skipDigits()
+	}
+	if digits == 0 {
+		return nil, &pySyntaxError{Column: start + 1, Msg: fmt.Sprintf("invalid number %q", p.s[start:p.pos])}
+	}
+	if c := p.peek(); c == 'e' || c == 'E' {
+		isFloat = true
+		p.pos++
+		if c := p.peek(); c == '-' || c == '+' {
+			p.pos++
+		}
+		if p.skipDigits() == 0 {
+			return nil, &pySyntaxError{Column: start + 1, Msg: fmt.Sprintf("invalid number %q", p.s[start:p.pos])}
+		}
+	}
+	text := p.s[start:p.pos]
+	if isFloat {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, &pySyntaxError{Column: start + 1, Msg: fmt.Sprintf("invalid number %q", text)}
+		}
+		return f, nil
+	}
+	i, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return nil, &pySyntaxError{Column: start + 1, Msg: fmt.Sprintf("invalid number %q", text)}
+	}
+	return i, nil
+}
+
+func (p *pyParser) skipDigits() (n int) {
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+		n++
+	}
+	return
+}
+
+// parseString - parse a single or double quoted string with Python escapes
+func (p *pyParser) parseString() (string, error) {
+	start := p.pos
+	quote := p.s[p.pos]
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.pos >= len(p.s) {
+			return "", &pySyntaxError{Column: start + 1, Msg: "unterminated string"}
+		}
+		c := p.s[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		case c == '\\':
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseEscape - parse the escape sequence at the backslash, unknown escapes are kept as is like Python does
+func (p *pyParser) parseEscape(sb *strings.Builder) error {
+	start := p.pos
+	p.pos++ // the backslash
+	if p.pos >= len(p.s) {
+		return &pySyntaxError{Column: start + 1, Msg: "unterminated string"}
+	}
+	c := p.s[p.pos]
+	p.pos++
+	simple := map[byte]byte{'\\': '\\', '\'': '\'', '"': '"', 'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v'}
+	if r, ok := simple[c]; ok {
+		sb.WriteByte(r)
+		return nil
+	}
+	hexLen := map[byte]int{'x': 2, 'u': 4, 'U': 8}[c]
+	switch {
+	case hexLen > 0:
+		if p.pos+hexLen > len(p.s) {
+			return &pySyntaxError{Column: start + 1, Msg: fmt.Sprintf("truncated \\%c escape", c)}
+		}
+		r, err := strconv.ParseUint(p.s[p.pos:p.pos+hexLen], 16, 32)
+		if err != nil || r > utf8.MaxRune {
+			return &pySyntaxError{Column: start + 1, Msg: fmt.Sprintf("invalid \\%c escape", c)}
+		}
+		p.pos += hexLen
+		sb.WriteRune(rune(r))
+	case c >= '0' && c <= '7':
+		end := p.pos
+		for end < len(p.s) && end < p.pos+2 && p.s[end] >= '0' && p.s[end] <= '7' {
+			end++
+		}
+		r, _ := strconv.ParseUint(p.s[p.pos-1:end], 8, 32)
+		p.pos = end
+		sb.WriteRune(rune(r))
+	default:
+		sb.WriteByte('\\')
+		sb.WriteByte(c)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package matcher
+
+import "sort"
+
+const (
+	// tokenWeight - score of every matched token, so longer supplier names rank before their prefixes
+	tokenWeight = 10.0
+	// gapWeight - penalty of every word skipped or line broken between two matched words
+	gapWeight = 1.0
+	// pageWeight - penalty of every page before the matched words
+	pageWeight = 1.0
+	// lineWeight - penalty of every line above the matched words, vendor names are usually printed on top
+	lineWeight = 0.01
+	// distanceWeight - penalty of every edit needed to match a word fuzzily, so exact matches rank first
+	distanceWeight = 2.0
+	// identifierWeight - score of every identifier of the supplier printed in the invoice, more than a name token
+	identifierWeight = 2 * tokenWeight
+)
+
+// scoreMatch - score the words matching the tokens of a supplier name, higher is better
+// more matched tokens rank first, then the words closer to each other, then the words closer to the top of the invoice
+func scoreMatch(tokenNum int, words []*Word) (score float64) {
+	if len(words) == 0 {
+		return 0
+	}
+	score = float64(tokenNum) * tokenWeight
+	for i := 1; i < len(words); i++ {
+		prev, cur := words[i-1], words[i]
+		if prev.LineId == cur.LineId {
+			score -= (float64(cur.PosId) - float64(prev.PosId) - 1) * gapWeight
+		} else {
+			score -= (float64(cur.LineId) - float64(prev.LineId)) * gapWeight
+		}
+	}
+	first := words[0]
+	score -= float64(first.PageId)*pageWeight + float64(first.LineId)*lineWeight
+	return score
+}
+
+func sumFloat(values []float64) (sum float64) {
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+// rankMatchResults - sort the results by score and keep the best result of every supplier
+// ties are broken by the line number in the supplier name file, so the ranking does not depend on the worker scheduling
+// return at most topK results, or all of them if topK is 0
+func rankMatchResults(results []*MatchResult, topK int) []*MatchResult {
+	sort.Slice(results, func(i, j int) bool {
+		ri, rj := results[i], results[j]
+		if ri.Score != rj.Score {
+			return ri.Score > rj.Score
+		}
+		return lessMatchResult(ri, rj)
+	})
+	ranked := make([]*MatchResult, 0, len(results))
+	seen := make(map[[2]string]bool)
+	for _, result := range results {
+		key := [2]string{result.SupplierId, result.SupplierName}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ranked = append(ranked, result)
+		if topK > 0 && len(ranked) == topK {
+			break
+		}
+	}
+	return ranked
+}
+
+// firstMatchResult - the result of the supplier first listed in the supplier name file, nil if there is no result
+func firstMatchResult(results []*MatchResult) (first *MatchResult) {
+	for _, result := range results {
+		if first == nil || lessMatchResult(result, first) {
+			first = result
+		}
+	}
+	return first
+}
+
+// lessMatchResult - order the results by the supplier name file line, then by the matched words
+func lessMatchResult(ri, rj *MatchResult) bool {
+	if ri.SupplierLine != rj.SupplierLine {
+		return ri.SupplierLine < rj.SupplierLine
+	}
+	if ri.SupplierId != rj.SupplierId {
+		return ri.SupplierId < rj.SupplierId
+	}
+	if ri.PageId != rj.PageId {
+		return ri.PageId < rj.PageId
+	}
+	wi, wj := ri.Words, rj.Words
+	for k := 0; k < len(wi) && k < len(wj); k++ {
+		if wi[k].LineId != wj[k].LineId {
+			return wi[k].LineId < wj[k].LineId
+		}
+		if wi[k].PosId != wj[k].PosId {
+			return wi[k].PosId < wj[k].PosId
+		}
+	}
+	return len(wi) < len(wj)
+}
@@ -0,0 +1,73 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func Test_scoreMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		better []*Word
+		worse  []*Word
+	}{
+		{
+			name:   "more tokens",
+			better: []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Company", LineId: 4, PosId: 1}},
+			worse:  []*Word{{Word: "Demo", LineId: 4, PosId: 0}},
+		},
+		{
+			name:   "adjacent words",
+			better: []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Company", LineId: 4, PosId: 1}},
+			worse:  []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Company", LineId: 4, PosId: 3}},
+		},
+		{
+			name:   "same line",
+			better: []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Company", LineId: 4, PosId: 1}},
+			worse:  []*Word{{Word: "Demo", LineId: 4, PosId: 0}, {Word: "Company", LineId: 6, PosId: 0}},
+		},
+		{
+			name:   "top of the page",
+			better: []*Word{{Word: "Demo", PageId: 1, LineId: 4, PosId: 0}},
+			worse:  []*Word{{Word: "Demo", PageId: 1, LineId: 40, PosId: 0}},
+		},
+		{
+			name:   "first page",
+			better: []*Word{{Word: "Demo", PageId: 1, LineId: 40, PosId: 0}},
+			worse:  []*Word{{Word: "Demo", PageId: 2, LineId: 4, PosId: 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if better, worse := scoreMatch(len(tt.better), tt.better), scoreMatch(len(tt.worse), tt.worse); better <= worse {
+				t.Errorf("scoreMatch() = %v, want greater than %v", better, worse)
+			}
+		})
+	}
+}
+
+func Test_rankMatchResults(t *testing.T) {
+	results := []*MatchResult{
+		{SupplierId: "1", SupplierName: "Demo", Score: 9},
+		{SupplierId: "2", SupplierName: "Demo Company", Score: 19},
+		{SupplierId: "2", SupplierName: "Demo Company", Score: 17},
+		{SupplierId: "3", SupplierName: "Company", Score: 8},
+	}
+	tests := []struct {
+		name string
+		topK int
+		want []string
+	}{
+		{name: "all", topK: 0, want: []string{"2", "1", "3"}},
+		{name: "top 2", topK: 2, want: []string{"2", "1"}},
+		{name: "top more than results", topK: 5, want: []string{"2", "1", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := rankMatchResults(append([]*MatchResult{}, results...), tt.topK)
+			if got := supplierIds(ranked); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rankMatchResults() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
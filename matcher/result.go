@@ -0,0 +1,67 @@
+package matcher
+
+// Strategy - the algorithm used to match the supplier name
+type Strategy string
+
+const (
+	StrategyTwoPointer   Strategy = "two-pointer"
+	StrategyBinarySearch Strategy = "binary-search"
+	StrategyIndex        Strategy = "index"
+	StrategyIdentifier   Strategy = "identifier" // only the identifiers of the supplier matched, see Options.MatchIdentifiers
+)
+
+// MatchResult - a supplier name matched in an invoice
+type MatchResult struct {
+	SupplierId   string    `json:"supplier_id"`
+	SupplierName string    `json:"supplier_name"`   // the canonical name of the supplier
+	Alias        string    `json:"alias,omitempty"` // the alias of the supplier matched instead of its name, see Supplier
+	SupplierLine int       `json:"supplier_line"`   // the line number in the supplier name file
+	PageId       uint32    `json:"page_id"`
+	Words        []*Word   `json:"words"`     // the invoice words matching the tokens of the supplier name, see tokenSpans
+	Distances    []float64 `json:"distances"` // the edit distance between every word in Words and the tokens it matches, see newMatchResult
+	Strategy     Strategy  `json:"strategy"`
+	Score        float64   `json:"score"` // higher is better, see scoreMatch
+
+	Identifiers []Identifier `json:"identifiers,omitempty"` // the identifiers of the supplier printed in the invoice
+}
+
+// Supplier - the supplier of the match result
+func (r *MatchResult) Supplier() *Supplier {
+	return &Supplier{
+		Id:           r.SupplierId,
+		SupplierName: r.SupplierName,
+		Line:         r.SupplierLine,
+		Alias:        r.Alias,
+		Identifiers:  r.Identifiers,
+	}
+}
+
+// newMatchResult - create the match result of supplier from the words matching the tokens of its name
+func newMatchResult(supplier *Supplier, tokens []string, matches []wordMatch, strategy Strategy) *MatchResult {
+	words := make([]*Word, 0, len(matches))
+	distances := make([]float64, 0, len(matches))
+	for _, m := range matches {
+		for i, w := range m.words {
+			words = append(words, w)
+			if i == 0 { // a run of adjacent words records its distance on the first word
+				distances = append(distances, m.distance)
+			} else {
+				distances = append(distances, 0)
+			}
+		}
+	}
+	result := &MatchResult{
+		SupplierId:   supplier.Id,
+		SupplierName: supplier.SupplierName,
+		Alias:        supplier.Alias,
+		SupplierLine: supplier.Line,
+		Words:        words,
+		Distances:    distances,
+		Strategy:     strategy,
+		Score:        scoreMatch(len(tokens), words) - sumFloat(distances)*distanceWeight,
+	}
+	if len(words) > 0 {
+		result.PageId = words[0].PageId
+	}
+	return result
+}
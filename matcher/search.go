@@ -0,0 +1,432 @@
+package matcher
+
+import (
+	"sort"
+	"sync"
+)
+
+type Word struct {
+	Word   string `json:"word"`
+	PosId  uint32 `json:"pos_id"`
+	PageId uint32 `json:"page_id"`
+	LineId uint32 `json:"line_id"`
+
+	// the ids of the word and of the table cell spans it is in, 0 if unknown
+	WordId  uint32 `json:"word_id"`
+	CspanId uint32 `json:"cspan_id"`
+	RspanId uint32 `json:"rspan_id"`
+
+	// the bounding box of the word, 0 if unknown
+	Left   float64 `json:"left"`
+	Top    float64 `json:"top"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Right  float64 `json:"right"`
+}
+
+type Page struct {
+	Words     []*Word
+	Keys      []string // the normalized key of every word in Words, Word.Word is used if it is nil
+	WordMap   map[string][]int
+	WordMapV2 map[string][]*Word
+
+	normalizer  Normalizer
+	maxDistance float64  // the max edit distance of a word matching the supplier name tokens, 0 means exact
+	fuzzyKeys   sync.Map // the cached keyMatch list of the supplier name tokens when maxDistance is set
+	fuzzyCount  int32    // the number of keys stored in fuzzyKeys, at most fuzzyKeyCacheSize
+	matchMerged bool     // whether a word can match several tokens written without space
+	maxJoin     int      // the max number of adjacent words joined to match the tokens
+	geometry    bool     // whether consecutive words are checked by their bounding box, see isNear
+	optSuffix   bool     // whether the legal suffixes at the end of a supplier name are optional, see nameTokens
+
+	identifierMap map[string][]*Word // the words of the identifiers printed in the page by key, nil if not matched
+	joinMap       map[string][]wordRun
+}
+
+// wordMatch - a word or a run of adjacent words in the page matching one or several tokens of the supplier name
+type wordMatch struct {
+	idx      int // the index of the first word in the page, -1 if unknown
+	count    int // the number of adjacent words matching the tokens
+	words    []*Word
+	distance float64
+}
+
+type Supplier struct {
+	SupplierName string
+	Id           string
+	Line         int    // the line number in the supplier name file, used to break ties between matches
+	Alias        string // the alias matched instead of the canonical SupplierName, empty for SupplierName itself
+
+	Identifiers []Identifier // the normalized tax numbers, phones, emails and domains of the supplier
+}
+
+// matchName - the name of the supplier matched in the invoice, its alias or its canonical name
+func (s *Supplier) matchName() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.SupplierName
+}
+
+// nameTokens - the normalized tokens of the supplier name to match in the page in order of preference,
+// followed by the tokens without the legal suffixes if they are optional, e.g. "Demo Pty Ltd" then "Demo",
+// and by the same tokens without the leading article if it is optional, e.g. "The Demo Co" then "Demo Co"
+func (page *Page) nameTokens(supplier *Supplier) (variants [][]string) {
+	tokens := page.normalizer.Tokens(supplier.matchName())
+	variants = [][]string{tokens}
+	if page.optSuffix {
+		if trimmed := page.normalizer.Equivalents.trimSuffix(tokens); trimmed != nil {
+			variants = append(variants, trimmed)
+		}
+	}
+	for _, variant := range variants {
+		if trimmed := page.normalizer.trimArticle(variant); trimmed != nil {
+			variants = append(variants, trimmed)
+		}
+	}
+	return variants
+}
+
+type SuppliersForPage struct {
+	Page      *Page
+	Suppliers []*Supplier
+}
+
+// SearchSupplierFromPage - find supplier name from a page
+// return nil if the supplier name is not found
+func SearchSupplierFromPage(pages []*Page, supplier *Supplier) *MatchResult {
+	for _, page := range pages {
+		for _, tokens := range page.nameTokens(supplier) {
+			matches := locateSupplierNameInPage(tokens, page)
+			if matches != nil {
+				return newMatchResult(supplier, tokens, matches, StrategyTwoPointer)
+			}
+		}
+	}
+	return nil
+}
+
+// SearchSupplierFromPageV2 - find supplier name from a page
+// return nil if the supplier name is not found
+func SearchSupplierFromPageV2(pages []*Page, supplier *Supplier) *MatchResult {
+	for _, page := range pages {
+		for _, tokens := range page.nameTokens(supplier) {
+			matches := locateSupplierNameInPageV2(tokens, page)
+			if matches != nil {
+				for i, m := range matches {
+					matches[i].words = page.Words[m.idx : m.idx+m.count]
+				}
+				return newMatchResult(supplier, tokens, matches, StrategyBinarySearch)
+			}
+		}
+	}
+	return nil
+}
+
+// SearchSupplierFromPageV3 - find supplier name from the potential suppliers of each page
+// return nil if the supplier name is not found
+func SearchSupplierFromPageV3(potentialSuppliersForPage []*SuppliersForPage) *MatchResult {
+	for _, suppliersForPage := range potentialSuppliersForPage {
+		for _, supplier := range suppliersForPage.Suppliers {
+			page := suppliersForPage.Page
+			var result *MatchResult
+			for _, tokens := range page.nameTokens(supplier) {
+				matches := locateSupplierNameInPageV3(tokens, page, nil)
+				if len(matches) > 0 {
+					result = newMatchResult(supplier, tokens, matches, StrategyIndex)
+					break
+				}
+			}
+			if result = page.matchIdentifiers(supplier, result); result != nil {
+				return result
+			}
+		}
+	}
+	return nil
+}
+
+// SearchSuppliersFromPageV3 - find all the supplier names from the potential suppliers of each page
+// return an empty list if no supplier name is found
+func SearchSuppliersFromPageV3(potentialSuppliersForPage []*SuppliersForPage) (results []*MatchResult) {
+	results = make([]*MatchResult, 0)
+	for _, suppliersForPage := range potentialSuppliersForPage {
+		for _, supplier := range suppliersForPage.Suppliers {
+			page := suppliersForPage.Page
+			var result *MatchResult
+			for _, tokens := range page.nameTokens(supplier) {
+				matches := locateSupplierNameInPageV3(tokens, page, nil)
+				if len(matches) > 0 {
+					result = newMatchResult(supplier, tokens, matches, StrategyIndex)
+					break
+				}
+			}
+			if result = page.matchIdentifiers(supplier, result); result != nil {
+				results = append(results, result)
+			}
+		}
+	}
+	return results
+}
+
+// groupInvoiceWords - group words in invoice file by page id
+func groupInvoiceWords(words []*Word) (pages []*Page) {
+	pages = make([]*Page, 0)
+	pageMap := make(map[uint32]*Page)
+	for _, word := range words {
+		page, ok := pageMap[word.PageId]
+		if !ok {
+			page = &Page{Words: make([]*Word, 0, 1)}
+			pageMap[word.PageId] = page
+			pages = append(pages, page)
+		}
+		page.Words = append(page.Words, word)
+	}
+	return pages
+}
+
+// matchSupplierNameInPage - match supplier name in the page
+func matchSupplierNameInPage(supplierNameToken []string, page *Page) (canMatch bool) {
+	return locateSupplierNameInPage(supplierNameToken, page) != nil
+}
+
+// locateSupplierNameInPage - find the words matching the supplier name in the page
+// return nil if the supplier name can not match
+func locateSupplierNameInPage(supplierNameToken []string, page *Page) (matches []wordMatch) {
+	if page == nil {
+		return nil
+	}
+	lenName := len(supplierNameToken)
+	lenPage := len(page.Words)
+	if lenName == 0 || lenPage == 0 {
+		return nil
+	}
+	matches = make([]wordMatch, 0, lenName)
+	idxName := 0
+	idxWord := 0
+	for idxName < lenName && idxWord < lenPage {
+		var prev *Word
+		if len(matches) > 0 {
+			last := matches[len(matches)-1]
+			prev = last.words[len(last.words)-1]
+		}
+		if !page.geometry || page.isNear(prev, page.Words[idxWord]) {
+			if end, count, distance := matchTokenSpan(supplierNameToken, idxName, page, idxWord); end > idxName {
+				matches = append(matches, wordMatch{idx: idxWord, count: count, words: page.Words[idxWord : idxWord+count], distance: distance})
+				idxName = end
+				idxWord += count
+				continue
+			}
+		}
+		idxWord++
+	}
+	if idxName != lenName {
+		return nil
+	}
+	return matches
+}
+
+// matchSupplierNameInPageV2 - match supplier name in the page
+func matchSupplierNameInPageV2(supplierNameToken []string, page *Page) (canMatch bool) {
+	return locateSupplierNameInPageV2(supplierNameToken, page) != nil
+}
+
+// locateSupplierNameInPageV2 - find the index of the words matching the supplier name in the page
+// return nil if the supplier name can not match
+func locateSupplierNameInPageV2(supplierNameToken []string, page *Page) (matches []wordMatch) {
+	if page == nil {
+		return nil
+	}
+	if len(supplierNameToken) == 0 || len(page.WordMap) == 0 {
+		return nil
+	}
+	return locateTokenSpansInPageV2(supplierNameToken, page, 0, nil)
+}
+
+// locateTokenSpansInPageV2 - find the index of the words matching the tokens in the page from idxWord after prev
+// a word can match several tokens and several adjacent words can match the tokens, see tokenSpans
+func locateTokenSpansInPageV2(supplierNameToken []string, page *Page, idxWord int, prev *Word) (matches []wordMatch) {
+	if len(supplierNameToken) == 0 {
+		return []wordMatch{}
+	}
+	for _, span := range page.tokenSpans(supplierNameToken) {
+		for _, km := range page.lookupKeys(span.key, span.joined) {
+			if span.joined {
+				runs := page.joinMap[km.key]
+				for res := searchRuns(runs, idxWord); res < len(runs); res++ {
+					run := runs[res]
+					if page.geometry && !page.isNear(prev, page.Words[run.start]) {
+						continue
+					}
+					if rest := locateTokenSpansInPageV2(supplierNameToken[span.end:], page, run.end, page.Words[run.end-1]); rest != nil {
+						return append([]wordMatch{{idx: run.start, count: run.end - run.start, distance: km.distance}}, rest...)
+					}
+					if !page.geometry { // the earliest run is the best choice without geometry
+						break
+					}
+				}
+				continue
+			}
+			wordList := page.WordMap[km.key]
+			// use binary search to find the next idx
+			for res := sort.SearchInts(wordList, idxWord); res < len(wordList); res++ {
+				var next *Word
+				if page.geometry {
+					next = page.Words[wordList[res]]
+					if !page.isNear(prev, next) {
+						continue
+					}
+				}
+				// jump to the next idx
+				if rest := locateTokenSpansInPageV2(supplierNameToken[span.end:], page, wordList[res]+1, next); rest != nil {
+					return append([]wordMatch{{idx: wordList[res], count: 1, distance: km.distance}}, rest...)
+				}
+				if !page.geometry { // the earliest word is the best choice without geometry
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// matchSupplierNameInPageV3 - match supplier name in the page
+func matchSupplierNameInPageV3(supplierNameToken []string, page *Page, startWord *Word) (canMatch bool) {
+	return locateSupplierNameInPageV3(supplierNameToken, page, startWord) != nil
+}
+
+// locateSupplierNameInPageV3 - find the words matching the supplier name in the page after startWord
+// return nil if the supplier name can not match
+func locateSupplierNameInPageV3(supplierNameToken []string, page *Page, startWord *Word) (matches []wordMatch) {
+	if page == nil {
+		return nil
+	}
+	if len(supplierNameToken) == 0 {
+		return []wordMatch{}
+	}
+	if len(page.WordMapV2) == 0 {
+		return nil
+	}
+
+	// a word can match several tokens and several adjacent words can match the tokens, see tokenSpans
+	for _, span := range page.tokenSpans(supplierNameToken) {
+		for _, km := range page.lookupKeys(span.key, span.joined) {
+			if span.joined {
+				runs := page.joinMap[km.key]
+				for _, run := range runs {
+					first, last := page.Words[run.start], page.Words[run.end-1]
+					if !isAfter(first, startWord) || !page.isNear(startWord, first) {
+						continue
+					}
+					if rest := locateSupplierNameInPageV3(supplierNameToken[span.end:], page, last); rest != nil {
+						return append([]wordMatch{{idx: run.start, count: run.end - run.start, words: page.Words[run.start:run.end], distance: km.distance}}, rest...)
+					}
+				}
+				continue
+			}
+			wordList := page.WordMapV2[km.key]
+			// use binary search to find the next idx
+			res := sort.Search(len(wordList), func(i int) bool {
+				return isAfter(wordList[i], startWord)
+			})
+
+			for i := res; i < len(wordList); i++ {
+				nextStartWord := wordList[i]
+				if page.isNear(startWord, nextStartWord) {
+					if rest := locateSupplierNameInPageV3(supplierNameToken[span.end:], page, nextStartWord); rest != nil {
+						return append([]wordMatch{{idx: -1, count: 1, words: []*Word{nextStartWord}, distance: km.distance}}, rest...)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// isAfter - whether wi is after wj in the page, every word is after a nil word
+func isAfter(wi, wj *Word) bool {
+	return wj == nil || wi.LineId > wj.LineId || wi.LineId == wj.LineId && wi.PosId > wj.PosId
+}
+
+// sortWordsInPage - sort the words by position id and line id
+func sortWordsInPage(page *Page) *Page {
+	if page == nil || len(page.Words) == 0 {
+		return page
+	}
+	sort.Sort(byPosAndLine(page.Words))
+	return page
+}
+
+// normalizeWordsInPage - compute the normalized key of the sorted words in the page
+func normalizeWordsInPage(page *Page, normalizer Normalizer) {
+	if page == nil {
+		return
+	}
+	page.normalizer = normalizer
+	page.Keys = make([]string, 0, len(page.Words))
+	for _, w := range page.Words {
+		page.Keys = append(page.Keys, normalizer.Key(w.Word))
+	}
+}
+
+// key - the normalized key of the word at idx
+func (page *Page) key(idx int) string {
+	if page.Keys == nil {
+		return page.Words[idx].Word
+	}
+	return page.Keys[idx]
+}
+
+func buildWordMapInPage(page *Page) {
+	if page == nil {
+		return
+	}
+	page.WordMap = make(map[string][]int)
+	for idx := range page.Words {
+		key := page.key(idx)
+		if key == "" {
+			continue
+		}
+		wordList, ok := page.WordMap[key]
+		if !ok {
+			wordList = make([]int, 0)
+		}
+		wordList = append(wordList, idx)
+		page.WordMap[key] = wordList
+	}
+}
+
+func buildWordMapV2InPage(page *Page) {
+	if page == nil {
+		return
+	}
+	page.WordMapV2 = make(map[string][]*Word)
+	for idx, w := range page.Words {
+		key := page.key(idx)
+		if key == "" {
+			continue
+		}
+		wordList, ok := page.WordMapV2[key]
+		if !ok {
+			wordList = make([]*Word, 0)
+		}
+		wordList = append(wordList, w)
+		page.WordMapV2[key] = wordList
+	}
+}
+
+// byPosAndLine - sort words by position id and line id
+type byPosAndLine []*Word
+
+func (s byPosAndLine) Len() int {
+	return len(s)
+}
+
+func (s byPosAndLine) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s byPosAndLine) Less(i, j int) bool {
+	wi := s[i]
+	wj := s[j]
+	return wi.LineId < wj.LineId || (wi.LineId == wj.LineId && wi.PosId < wj.PosId)
+}
@@ -1,4 +1,4 @@
-package main
+package matcher
 
 import (
 	"reflect"
@@ -11,9 +11,8 @@ func Search(invoiceWords []*Word, suppliers []*Supplier) (supplier *Supplier) {
 		sortWordsInPage(page)
 	}
 	for _, s := range suppliers {
-		supplier = SearchSupplierFromPage(pages, s)
-		if supplier != nil {
-			return
+		if result := SearchSupplierFromPage(pages, s); result != nil {
+			return result.Supplier()
 		}
 	}
 	return nil
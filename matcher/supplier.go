@@ -0,0 +1,242 @@
+package matcher
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// DefaultIdColumn - the header of the supplier id column
+	DefaultIdColumn = "Id"
+	// DefaultNameColumn - the header of the supplier name column
+	DefaultNameColumn = "SupplierName"
+	// DefaultAliasColumn - the header of the optional supplier alias column
+	DefaultAliasColumn = "Aliases"
+	// aliasSeparator - the separator of the aliases of a supplier in the alias column
+	aliasSeparator = "|"
+)
+
+// SupplierFileError - a malformed record in the supplier name file
+type SupplierFileError struct {
+	File string
+	Line int
+	Text string // the fields of the record joined by comma
+	Err  error  // the CSV syntax error, nil if the record was parsed
+}
+
+func (e *SupplierFileError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s:%d: invalid supplier name text: %v", e.File, e.Line, e.Err)
+	}
+	return fmt.Sprintf("%s:%d: invalid supplier name text: %q", e.File, e.Line, e.Text)
+}
+
+func (e *SupplierFileError) Unwrap() error {
+	return e.Err
+}
+
+// SupplierFileStats - the statistics of a supplier name file read by BuildIndex
+type SupplierFileStats struct {
+	Skipped int // the number of malformed records skipped with Options.Lenient
+}
+
+// supplierColumns - the indexes of the supplier id, name, alias and identifier columns in the supplier name file
+type supplierColumns struct {
+	id, name    int
+	alias       int                    // -1 without alias column
+	identifiers map[int]IdentifierType // the identifier columns, empty unless Options.MatchIdentifiers
+	last        bool                   // the name is the last column, so the fields after it are commas in an unquoted name
+}
+
+// loadSupplierNameFile - load supplier names from an RFC 4180 CSV file asynchronously
+// The first record is a header if it contains the id or name column of opts, otherwise the id and the name are
+// the first two columns. Supplier ids can be any text. A supplier is loaded once with its name and once with
+// every alias listed in the alias column separated by "|", e.g. "HOUSE OF FINE FOODS LIMITED,House of Fine Foods|HOFF".
+// With opts.MatchIdentifiers the valid values of the identifier columns are attached to the supplier and its aliases,
+// the invalid ones are ignored. supplierChan is always closed when the loader stops,
+// and errChan receives the error that stopped it if any. The loader stops reading once ctx is done.
+// In lenient mode malformed records are skipped and counted in stats instead, which is complete once errChan is closed.
+func loadSupplierNameFile(ctx context.Context, supplierNameFilePath string, opts Options) (supplierChan chan *Supplier, errChan chan error, stats *SupplierFileStats, err error) {
+	bufSize := 100
+	supplierNameFile, err := os.Open(supplierNameFilePath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	stats = &SupplierFileStats{}
+	supplierChan = make(chan *Supplier, bufSize)
+	errChan = make(chan error, 1)
+	go func() {
+		defer close(errChan)
+		defer close(supplierChan)
+		defer supplierNameFile.Close()
+		reader := newSupplierReader(supplierNameFile)
+		var columns *supplierColumns
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				if opts.Lenient {
+					stats.Skipped++
+					continue
+				}
+				errChan <- &SupplierFileError{File: supplierNameFilePath, Line: parseErr.StartLine, Err: parseErr.Err}
+				return
+			}
+			if err != nil {
+				errChan <- err
+				return
+			}
+			// the position is only known once the record is parsed
+			line, _ := reader.FieldPos(0)
+			if columns == nil {
+				var header bool
+				if columns, header, err = detectSupplierColumns(record, opts); err != nil {
+					errChan <- &SupplierFileError{File: supplierNameFilePath, Line: line, Text: strings.Join(record, ","), Err: err}
+					return
+				}
+				if header {
+					continue
+				}
+			}
+			suppliers := columns.suppliers(record, line)
+			if suppliers == nil {
+				if opts.Lenient {
+					stats.Skipped++
+					continue
+				}
+				errChan <- &SupplierFileError{File: supplierNameFilePath, Line: line, Text: strings.Join(record, ",")}
+				return
+			}
+			for _, supplier := range suppliers {
+				select {
+				case supplierChan <- supplier:
+				case <-ctx.Done(): // stop reading once the search is canceled or finished
+					return
+				}
+			}
+		}
+	}()
+	return
+}
+
+// newSupplierReader - a CSV reader of records with any number of fields
+func newSupplierReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	return reader
+}
+
+// detectSupplierColumns - find the id and name columns in the header of the supplier name file
+// header is false if the first record doesn't contain any of the column names, it is a supplier then
+func detectSupplierColumns(record []string, opts Options) (columns *supplierColumns, header bool, err error) {
+	idColumn, nameColumn, aliasColumn := opts.IdColumn, opts.NameColumn, opts.AliasColumn
+	if idColumn == "" {
+		idColumn = DefaultIdColumn
+	}
+	if nameColumn == "" {
+		nameColumn = DefaultNameColumn
+	}
+	if aliasColumn == "" {
+		aliasColumn = DefaultAliasColumn
+	}
+	identifierColumns := opts.IdentifierColumns
+	if identifierColumns == nil {
+		identifierColumns = DefaultIdentifierColumns()
+	}
+	columns = &supplierColumns{id: -1, name: -1, alias: -1, identifiers: map[int]IdentifierType{}}
+	for i, field := range record {
+		field = strings.TrimSpace(strings.TrimPrefix(field, "\ufeff")) // the byte order mark of Excel exports
+		switch {
+		case strings.EqualFold(field, idColumn) && columns.id < 0:
+			columns.id = i
+		case strings.EqualFold(field, nameColumn) && columns.name < 0:
+			columns.name = i
+		case strings.EqualFold(field, aliasColumn) && columns.alias < 0:
+			columns.alias = i
+		case opts.MatchIdentifiers:
+			for column, t := range identifierColumns {
+				if strings.EqualFold(field, column) {
+					columns.identifiers[i] = t
+				}
+			}
+		}
+	}
+	switch {
+	case columns.id < 0 && columns.name < 0:
+		return &supplierColumns{id: 0, name: 1, alias: -1, last: true}, false, nil
+	case columns.id < 0:
+		return nil, true, fmt.Errorf("missing column %q in header", idColumn)
+	case columns.name < 0:
+		return nil, true, fmt.Errorf("missing column %q in header", nameColumn)
+	}
+	columns.last = columns.name == len(record)-1
+	return columns, true, nil
+}
+
+// suppliers - the supplier of the record at line followed by its aliases, nil if the id or the name is missing
+func (c *supplierColumns) suppliers(record []string, line int) (suppliers []*Supplier) {
+	if c.id >= len(record) || c.name >= len(record) {
+		return nil
+	}
+	id := strings.TrimSpace(record[c.id])
+	name := record[c.name]
+	if c.last {
+		name = strings.Join(record[c.name:], ",")
+	}
+	name = strings.TrimSpace(name)
+	if id == "" || name == "" {
+		return nil
+	}
+	identifiers := c.parseIdentifiers(record)
+	suppliers = []*Supplier{{
+		Id:           id,
+		SupplierName: name,
+		Line:         line,
+		Identifiers:  identifiers,
+	}}
+	if c.alias < 0 || c.alias >= len(record) {
+		return
+	}
+	seen := map[string]bool{name: true}
+	for _, alias := range strings.Split(record[c.alias], aliasSeparator) {
+		alias = strings.TrimSpace(alias)
+		if alias == "" || seen[alias] {
+			continue
+		}
+		seen[alias] = true
+		suppliers = append(suppliers, &Supplier{
+			Id:           id,
+			SupplierName: name,
+			Line:         line,
+			Alias:        alias,
+			Identifiers:  identifiers,
+		})
+	}
+	return
+}
+
+// parseIdentifiers - the valid identifiers of the identifier columns of the record in column order, nil if there is none
+func (c *supplierColumns) parseIdentifiers(record []string) (identifiers []Identifier) {
+	seen := make(map[Identifier]bool)
+	for i, field := range record {
+		t, ok := c.identifiers[i]
+		if !ok {
+			continue
+		}
+		for _, value := range strings.Split(field, aliasSeparator) {
+			if id, ok := normalizeIdentifier(t, strings.TrimSpace(value)); ok && !seen[id] {
+				seen[id] = true
+				identifiers = append(identifiers, id)
+			}
+		}
+	}
+	return
+}
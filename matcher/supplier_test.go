@@ -0,0 +1,181 @@
+package matcher
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_loadSupplierNameFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		opts    Options
+		want    []*Supplier
+		skipped int
+	}{
+		{
+			name:    "header",
+			content: "Id,SupplierName\n1,Demo Company\n2,Another Company\n",
+			opts:    DefaultOptions(),
+			want:    []*Supplier{{Id: "1", SupplierName: "Demo Company", Line: 2}, {Id: "2", SupplierName: "Another Company", Line: 3}},
+		},
+		{
+			name:    "no header",
+			content: "1,Demo Company\n2,Another Company\n",
+			opts:    DefaultOptions(),
+			want:    []*Supplier{{Id: "1", SupplierName: "Demo Company", Line: 1}, {Id: "2", SupplierName: "Another Company", Line: 2}},
+		},
+		{
+			name:    "quoted",
+			content: "Id,SupplierName\n1,\"Smith, Jones & Co\"\n2,\"The \"\"Best\"\" Co\"\n3,\"Two\nLines\"\n4,Demo\n",
+			opts:    DefaultOptions(),
+			want: []*Supplier{
+				{Id: "1", SupplierName: "Smith, Jones & Co", Line: 2},
+				{Id: "2", SupplierName: `The "Best" Co`, Line: 3},
+				{Id: "3", SupplierName: "Two\nLines", Line: 4},
+				{Id: "4", SupplierName: "Demo", Line: 6},
+			},
+		},
+		{
+			name:    "unquoted comma in the last column",
+			content: "1,Smith, Jones & Co\n",
+			opts:    DefaultOptions(),
+			want:    []*Supplier{{Id: "1", SupplierName: "Smith, Jones & Co", Line: 1}},
+		},
+		{
+			name:    "configured columns",
+			content: "\ufeffName,ABN,code\r\nDemo Company,51 824 753 556,AB-1\r\n\"Smith, Jones & Co\",,AB-2\r\n",
+			opts:    Options{IdColumn: "Code", NameColumn: "name"},
+			want:    []*Supplier{{Id: "AB-1", SupplierName: "Demo Company", Line: 2}, {Id: "AB-2", SupplierName: "Smith, Jones & Co", Line: 3}},
+		},
+		{
+			name:    "aliases",
+			content: "Id,SupplierName,Aliases\n1,HOUSE OF FINE FOODS LIMITED,House of Fine Foods | HOFF||HOUSE OF FINE FOODS LIMITED\n2,Demo\n",
+			opts:    DefaultOptions(),
+			want: []*Supplier{
+				{Id: "1", SupplierName: "HOUSE OF FINE FOODS LIMITED", Line: 2},
+				{Id: "1", SupplierName: "HOUSE OF FINE FOODS LIMITED", Line: 2, Alias: "House of Fine Foods"},
+				{Id: "1", SupplierName: "HOUSE OF FINE FOODS LIMITED", Line: 2, Alias: "HOFF"},
+				{Id: "2", SupplierName: "Demo", Line: 3},
+			},
+		},
+		{
+			name:    "lenient",
+			content: "Id,SupplierName\n1,Demo\n2\n,Empty Id\n3,\"bad\"quote\n4,Another\n",
+			opts:    Options{Lenient: true},
+			want:    []*Supplier{{Id: "1", SupplierName: "Demo", Line: 2}, {Id: "4", SupplierName: "Another", Line: 6}},
+			skipped: 3,
+		},
+		{
+			name:    "lenient bare quote in the first field",
+			content: "Id,SupplierName\nab\"c,Foo\n3153303,Demo Company\n",
+			opts:    Options{Lenient: true},
+			want:    []*Supplier{{Id: "3153303", SupplierName: "Demo Company", Line: 3}},
+			skipped: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			supplierChan, errChan, stats, err := loadSupplierNameFile(context.Background(), writeSupplierNameFile(t, tt.content), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make([]*Supplier, 0)
+			for supplier := range supplierChan {
+				got = append(got, supplier)
+			}
+			if err := <-errChan; err != nil {
+				t.Fatalf("loadSupplierNameFile() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadSupplierNameFile() = %+v, want %+v", got, tt.want)
+			}
+			if stats.Skipped != tt.skipped {
+				t.Errorf("loadSupplierNameFile() skipped %d records, want %d", stats.Skipped, tt.skipped)
+			}
+		})
+	}
+}
+
+func Test_loadSupplierNameFile_error(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		opts    Options
+		line    int
+	}{
+		{name: "missing name", content: "Id,SupplierName\n1,Demo\n2\n", opts: DefaultOptions(), line: 3},
+		{name: "bare quote", content: "Id,SupplierName\n1,Demo\n\n3,\"bad\"quote\n", opts: DefaultOptions(), line: 4},
+		{name: "bare quote in the first field", content: "Id,SupplierName\nab\"c,Foo\n3153303,Demo Company\n", opts: DefaultOptions(), line: 2},
+		{name: "missing column", content: "Code,SupplierName\n1,Demo\n", opts: Options{IdColumn: "Id"}, line: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeSupplierNameFile(t, tt.content)
+			supplierChan, errChan, _, err := loadSupplierNameFile(context.Background(), path, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range supplierChan {
+			}
+			err = <-errChan
+			var fileErr *SupplierFileError
+			if !errors.As(err, &fileErr) {
+				t.Fatalf("loadSupplierNameFile() error = %v, want SupplierFileError", err)
+			}
+			if fileErr.File != path || fileErr.Line != tt.line {
+				t.Errorf("loadSupplierNameFile() error = %v, want line %d", err, tt.line)
+			}
+		})
+	}
+}
+
+func TestFindSupplierNameV2_csv(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Code,Name\nAB-1,\"Smith, Jones & Co\"\nAB-2,\"The \"\"Best\"\" Co\"\nAB-3,Demo\n")
+	invoiceFilePath := writeInvoiceFile(t, "INVOICE\nfrom Smith, Jones & Co\n")
+	opts := DefaultOptions()
+	opts.IdColumn = "code"
+	opts.NameColumn = "name"
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	got, _, err := FindSupplierNameV2(context.Background(), invoiceFilePath, supplierNameFilePath, opts)
+	if err != nil {
+		t.Fatalf("FindSupplierNameV2() error = %v", err)
+	}
+	want := &Supplier{Id: "AB-1", SupplierName: "Smith, Jones & Co", Line: 2}
+	if !reflect.DeepEqual(got.Supplier(), want) {
+		t.Errorf("FindSupplierNameV2() = %+v, want %+v", got.Supplier(), want)
+	}
+}
+
+func TestFindSupplierName_alias(t *testing.T) {
+	supplierNameFilePath := writeSupplierNameFile(t, "Id,SupplierName,Aliases\n1,Demo Company,\n2,HOUSE OF FINE FOODS LIMITED,House of Fine Foods|HOFF\n")
+	invoiceFilePath := writeInvoiceFile(t, "INVOICE\nHouse of Fine Foods\nThank you for shopping at HOFF\n")
+	opts := DefaultOptions()
+	if _, err := BuildIndex(supplierNameFilePath, opts); err != nil {
+		t.Fatalf("BuildIndex() error = %v", err)
+	}
+	want := &Supplier{Id: "2", SupplierName: "HOUSE OF FINE FOODS LIMITED", Line: 3, Alias: "House of Fine Foods"}
+	for _, find := range []func(context.Context, string, string, Options) (*MatchResult, SupplierFileStats, error){FindSupplierName, FindSupplierNameV2} {
+		got, _, err := find(context.Background(), invoiceFilePath, supplierNameFilePath, opts)
+		if err != nil {
+			t.Fatalf("find() error = %v", err)
+		}
+		if !reflect.DeepEqual(got.Supplier(), want) {
+			t.Errorf("find() = %+v, want %+v", got.Supplier(), want)
+		}
+	}
+	// the aliases of a supplier are ranked as the supplier
+	for _, find := range []func(context.Context, string, string, Options) ([]*MatchResult, SupplierFileStats, error){FindSupplierNames, FindSupplierNamesV2} {
+		got, _, err := find(context.Background(), invoiceFilePath, supplierNameFilePath, opts)
+		if err != nil {
+			t.Fatalf("find() error = %v", err)
+		}
+		if len(got) != 1 || got[0].SupplierId != "2" || got[0].Alias != "House of Fine Foods" {
+			t.Errorf("find() = %v, want the alias House of Fine Foods of supplier 2", got)
+		}
+	}
+}
@@ -1,338 +1,211 @@
 package main
 
 import (
-	"bufio"
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io/ioutil"
 	"log"
-	"os"
-	"regexp"
-	"strconv"
 	"strings"
-	"sync"
+
+	"wordsearch/matcher"
 )
 
 const (
 	CMD_SEARCH    = "search"
 	CMD_INDEX     = "index"
 	CMD_SEARCH_V2 = "searchv2"
+	CMD_CONVERT   = "convert"
+	CMD_UPDATE    = "index-update"
+	CMD_COMPACT   = "index-compact"
 )
 
 func main() {
 	invoiceFilePath := flag.String("invoice", "invoice.txt", "words of an invoice")
 	supplierNameFilePath := flag.String("supplier", "suppliernames.txt", "a list of supplier names")
-	cmd := flag.String("cmd", CMD_SEARCH, "run command search,index")
+	cmd := flag.String("cmd", CMD_SEARCH, "run command search,index,searchv2,convert,index-update,index-compact")
+	deltaFilePath := flag.String("delta", "", "the CSV file of the suppliers to add, update or delete with -cmd=index-update")
+	invoiceFormat := flag.String("format", string(matcher.InvoiceFormatAuto), "the invoice format auto,pydict,json,jsonl,hocr,alto,text")
 	workerNum := flag.Uint64("worker", 5, "number of workers")
+	jsonOutput := flag.Bool("json", false, "print the match result as JSON")
+	timeout := flag.Duration("timeout", 0, "stop searching after the timeout, 0 means no timeout")
+	all := flag.Bool("all", false, "return all matching suppliers ranked by score")
+	topK := flag.Int("top", 0, "the number of ranked suppliers returned with -all, 0 means all of them")
+	maxDistance := flag.Float64("distance", 0, "the max edit distance of an OCR word matching a supplier name token, 0 means exact")
+	merged := flag.Bool("merged", false, "let an OCR word match several supplier name tokens written without space")
+	maxJoin := flag.Int("join", 0, "the max number of adjacent OCR words on a line joined to match supplier name tokens")
+	geometry := flag.Bool("geometry", false, "require the words of a supplier name to be spatially close by their bounding box")
+	exact := flag.Bool("exact", false, "match the supplier names exactly instead of ignoring case and punctuation")
+	lenient := flag.Bool("lenient", false, "skip malformed records in the supplier file instead of failing")
+	idColumn := flag.String("id-column", matcher.DefaultIdColumn, "the header of the supplier id column in the supplier file")
+	nameColumn := flag.String("name-column", matcher.DefaultNameColumn, "the header of the supplier name column in the supplier file")
+	aliasColumn := flag.String("alias-column", matcher.DefaultAliasColumn, "the header of the supplier alias column in the supplier file, aliases are separated by |")
+	legalForms := flag.Bool("legal-forms", false, "match equivalent company forms such as Ltd/Limited, Pty/Proprietary, Inc/Incorporated, Co/Company and &/and")
+	equivalents := flag.String("equivalents", "", "a CSV file of equivalent tokens replacing the ones of -legal-forms, e.g. legal,ltd,limited or word,and,&")
+	optionalSuffix := flag.Bool("optional-suffix", false, "match supplier names without their legal suffix, e.g. Demo Pty Ltd matches Demo")
+	identifiers := flag.Bool("identifiers", false, "match the tax numbers, phones, emails and domains of the supplier file columns TaxNumber,ABN,VAT,Phone,Email,Domain,Website")
+	minTokenFraction := flag.Float64("token-fraction", matcher.DefaultMinTokenFraction, "the min fraction of the tokens of a supplier name found in a page to search it with -cmd=searchv2")
+	rebuildStale := flag.Bool("rebuild-stale", false, "build the index again with -cmd=searchv2 if the supplier file changed since it was built")
+	indexMemory := flag.Int64("index-memory", matcher.DefaultIndexMemoryBudget>>20, "the memory budget in MB of the postings buffered by -cmd=index, they are spilled to temporary files once exceeded, 0 means no limit")
 	flag.Parse()
 
-	if *cmd == CMD_SEARCH {
-		if err := FindSupplierName(*invoiceFilePath, *supplierNameFilePath, *workerNum); err != nil {
-			log.Fatal(err)
-		}
-	} else if *cmd == CMD_INDEX {
-		if err := BuildIndex(*supplierNameFilePath); err != nil {
-			log.Fatal(err)
-		}
-	} else if *cmd == CMD_SEARCH_V2 {
-		if err := FindSupplierNameV2(*invoiceFilePath, *supplierNameFilePath, *workerNum); err != nil {
+	opts := matcher.DefaultOptions()
+	opts.WorkerNum = *workerNum
+	opts.InvoiceFormat = matcher.InvoiceFormat(*invoiceFormat)
+	opts.Lenient = *lenient
+	opts.IdColumn = *idColumn
+	opts.NameColumn = *nameColumn
+	opts.AliasColumn = *aliasColumn
+	opts.TopK = *topK
+	opts.MaxDistance = *maxDistance
+	opts.MatchMerged = *merged
+	opts.MaxJoin = *maxJoin
+	opts.Geometry = *geometry
+	opts.OptionalSuffix = *optionalSuffix
+	opts.MatchIdentifiers = *identifiers
+	opts.MinTokenFraction = *minTokenFraction
+	opts.RebuildStaleIndex = *rebuildStale
+	opts.IndexMemoryBudget = *indexMemory << 20
+	if *exact {
+		opts.Normalizer = matcher.Normalizer{}
+	}
+	if *equivalents != "" {
+		table, err := matcher.LoadEquivalents(*equivalents)
+		if err != nil {
 			log.Fatal(err)
 		}
-	} else {
-		log.Fatal("invalid cmd")
+		opts.Normalizer.Equivalents = table
+	} else if *legalForms || *optionalSuffix {
+		opts.Normalizer.Equivalents = matcher.DefaultEquivalents()
 	}
 
-}
-
-func BuildIndex(supplierNameFilePath string) (err error) {
-	supplierChan, err := loadSupplierNameFile(supplierNameFilePath)
-	if err != nil {
-		return err
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
 	}
 
-	supplierMap := map[string][]*Supplier{}
-	for supplier := range supplierChan {
-		nameToken := strings.Split(supplier.SupplierName, " ")
-		if len(nameToken) < 1 {
-			log.Fatal("invalid supplier name")
+	if *cmd == CMD_INDEX {
+		stats, err := matcher.BuildIndex(*supplierNameFilePath, opts)
+		if err != nil {
+			log.Fatal(err)
 		}
-		firstName := nameToken[0]
-		suppliers, ok := supplierMap[firstName]
-		if !ok {
-			suppliers = make([]*Supplier, 0)
+		if stats.Skipped > 0 {
+			log.Printf("skipped %d invalid supplier name lines in %s", stats.Skipped, *supplierNameFilePath)
 		}
-		suppliers = append(suppliers, supplier)
-		supplierMap[firstName] = suppliers
-	}
-
-	indexMap := map[string]uint64{}
-	f, err := os.Create(fmt.Sprintf("%s.indexed", supplierNameFilePath))
-	defer f.Close()
-	if err != nil {
-		return err
+		return
 	}
-	currentIdx := uint64(0)
-	for firstName, suppliers := range supplierMap {
-		var buf bytes.Buffer
-		for _, supplier := range suppliers {
-			_, err := buf.WriteString(fmt.Sprintf("%s,%s\n", supplier.Id, supplier.SupplierName))
-			if err != nil {
-				return err
-			}
-		}
-		n, err := f.Write(buf.Bytes())
-		if err != nil {
-			return err
+	if *cmd == CMD_CONVERT {
+		if err := matcher.ConvertIndex(*supplierNameFilePath, opts); err != nil {
+			log.Fatal(err)
 		}
-		indexMap[firstName] = currentIdx
-		currentIdx += uint64(n)
-	}
-	idxf, err := os.Create(fmt.Sprintf("%s.idx", supplierNameFilePath))
-	defer idxf.Close()
-	if err != nil {
-		return err
+		return
 	}
-	indexJson, err := json.Marshal(indexMap)
-	if err != nil {
-		return err
+	if *cmd == CMD_UPDATE {
+		if err := matcher.UpdateIndex(*supplierNameFilePath, *deltaFilePath, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	_, err = idxf.Write(indexJson)
-	return
-}
-
-func FindSupplierNameV2(invoiceFilePath, supplierNameFilePath string, workerNum uint64) (err error) {
-	if workerNum == 0 {
-		return fmt.Errorf("invalid worker num")
+	if *cmd == CMD_COMPACT {
+		if err := matcher.CompactIndex(*supplierNameFilePath, opts); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	// preprocess the invoice file
-	words, err := loadInvoiceFile(invoiceFilePath)
-	if err != nil {
-		return err
+	var result *matcher.MatchResult
+	var results []*matcher.MatchResult
+	var stats matcher.SupplierFileStats
+	var err error
+	switch {
+	case *cmd == CMD_SEARCH && *all:
+		results, stats, err = matcher.FindSupplierNames(ctx, *invoiceFilePath, *supplierNameFilePath, opts)
+	case *cmd == CMD_SEARCH:
+		result, stats, err = matcher.FindSupplierName(ctx, *invoiceFilePath, *supplierNameFilePath, opts)
+	case *cmd == CMD_SEARCH_V2 && *all:
+		results, stats, err = matcher.FindSupplierNamesV2(ctx, *invoiceFilePath, *supplierNameFilePath, opts)
+	case *cmd == CMD_SEARCH_V2:
+		result, stats, err = matcher.FindSupplierNameV2(ctx, *invoiceFilePath, *supplierNameFilePath, opts)
+	default:
+		log.Fatal("invalid cmd")
 	}
-	pages := groupInvoiceWords(words)
-	for _, page := range pages {
-		sortWordsInPage(page)
-		buildWordMapV2InPage(page)
+	if stats.Skipped > 0 {
+		log.Printf("skipped %d invalid supplier name lines in %s", stats.Skipped, *supplierNameFilePath)
 	}
-
-	indexMap, supplierNameFile, err := loadSupplierNameFileWithIndex(supplierNameFilePath)
-	if err != nil {
-		return
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Fatalf("supplier name search timed out after %s", *timeout)
 	}
-	potentialSuppliersForPage, err := filterPotentialSuppliersForPage(pages, indexMap, supplierNameFile)
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
 
-	supplier := SearchSupplierFromPageV3(potentialSuppliersForPage)
-	if supplier != nil {
-		log.Printf("supplier name found: %s,%s", supplier.Id, supplier.SupplierName)
-	} else {
-		log.Println("supplier name not found")
-	}
-
-	return nil
-}
-
-func filterPotentialSuppliersForPage(pages []*Page, indexMap map[string]uint64, supplierNameFile *os.File) (suppliersForPage []*SuppliersForPage, err error) {
-	suppliersForPage = make([]*SuppliersForPage, 0)
-	for _, page := range pages {
-		suppliers := make([]*Supplier, 0)
-		for _, word := range page.Words {
-			idx, ok := indexMap[word.Word]
-			if !ok {
-				continue
-			}
-			_, err = supplierNameFile.Seek(int64(idx), 0)
-			if err != nil {
-				return
-			}
-			reg := regexp.MustCompile(`(\d+),(.+)`)
-			scanner := bufio.NewScanner(supplierNameFile)
-			for scanner.Scan() {
-				line := scanner.Text()
-				match := reg.FindStringSubmatch(line)
-				if len(match) != 3 {
-					err = fmt.Errorf("invalid supplier name text")
-					return
-				}
-				id := match[1]
-				supplierName := match[2]
-				tempWords := strings.Split(supplierName, " ")
-				if len(tempWords) < 1 || tempWords[0] != word.Word {
-					break
-				}
-				suppliers = append(suppliers, &Supplier{
-					Id:           id,
-					SupplierName: supplierName,
-				})
-			}
+	if *all {
+		if *jsonOutput {
+			printJson(results)
+			return
 		}
-		if len(suppliers) > 0 {
-			suppliersForPage = append(suppliersForPage, &SuppliersForPage{
-				Page:      page,
-				Suppliers: suppliers,
-			})
+		if len(results) == 0 {
+			log.Println("supplier name not found")
+		}
+		for i, result := range results {
+			log.Printf("#%d supplier name found: %s,%s%s%s score=%.2f", i+1, result.SupplierId, result.SupplierName, formatAlias(result), formatIdentifiers(result), result.Score)
+			log.Printf("#%d matched words on page %d: %s", i+1, result.PageId, formatWords(result))
 		}
-	}
-	return
-}
-
-func loadSupplierNameFileWithIndex(supplierNameFilePath string) (indexMap map[string]uint64, supplierNameFile *os.File, err error) {
-	idxf, err := os.Open(fmt.Sprintf("%s.idx", supplierNameFilePath))
-	if err != nil {
 		return
 	}
-	idxJson, err := ioutil.ReadAll(idxf)
-	if err != nil {
+	if *jsonOutput {
+		printJson(result)
 		return
 	}
-	err = json.Unmarshal(idxJson, &indexMap)
-	if err != nil {
-		return
+	if result != nil {
+		log.Printf("supplier name found: %s,%s%s%s", result.SupplierId, result.SupplierName, formatAlias(result), formatIdentifiers(result))
+		log.Printf("matched words on page %d: %s", result.PageId, formatWords(result))
+	} else {
+		log.Println("supplier name not found")
 	}
-	supplierNameFile, err = os.Open(fmt.Sprintf("%s.indexed", supplierNameFilePath))
-	return
 }
 
-// FindSupplierName - find the supplier name from input files
-// if supplier name is found, it will print the id and supplier name of the supplier
-func FindSupplierName(invoiceFilePath, supplierNameFilePath string, workerNum uint64) (err error) {
-	if workerNum == 0 {
-		return fmt.Errorf("invalid worker num")
-	}
-
-	// preprocess the invoice file
-	words, err := loadInvoiceFile(invoiceFilePath)
-	if err != nil {
-		return err
-	}
-	pages := groupInvoiceWords(words)
-	for _, page := range pages {
-		sortWordsInPage(page)
-		buildWordMapInPage(page)
-	}
-
-	// preprocess the supplier name file
-	supplierChan, err := loadSupplierNameFile(supplierNameFilePath)
+// printJson - print the match result as JSON
+func printJson(v interface{}) {
+	resultJson, err := json.Marshal(v)
 	if err != nil {
-		return err
+		log.Fatal(err)
 	}
-
-	var wg sync.WaitGroup
-	done := make(chan bool, 1)
-	// send worker job
-	for i := uint64(0); i < workerNum; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			runWorker(pages, supplierChan, done)
-		}()
-	}
-	// wait for all worker complete
-	wg.Wait()
-	select {
-	case <-done:
-		return nil
-	default:
-		log.Println("supplier name not found")
-	}
-	return nil
+	fmt.Println(string(resultJson))
 }
 
-// runWorker - run worker to find the supplier name
-func runWorker(pages []*Page, supplierChan chan *Supplier, done chan bool) {
-	for supplier := range supplierChan {
-		select {
-		case <-done: // stop early if other worker has found the supplier name
-			done <- true
-			return
-		default:
-			s := SearchSupplierFromPageV2(pages, supplier)
-			if s != nil {
-				log.Printf("supplier name found: %s,%s", s.Id, s.SupplierName)
-				done <- true
-				return
-			}
-		}
+// formatAlias - format the alias of the supplier matched instead of its name
+func formatAlias(result *matcher.MatchResult) string {
+	if result.Alias == "" {
+		return ""
 	}
+	return fmt.Sprintf(" (alias %q)", result.Alias)
 }
 
-// loadSupplierNameFile - load supplier names from file asynchronously
-func loadSupplierNameFile(supplierNameFilePath string) (supplierChan chan *Supplier, err error) {
-	bufSize := 100
-	supplierChan = make(chan *Supplier, bufSize)
-	supplierNameFile, err := os.Open(supplierNameFilePath)
-	if err != nil {
-		return nil, err
+// formatIdentifiers - format the identifiers of the supplier found in the invoice
+func formatIdentifiers(result *matcher.MatchResult) string {
+	if len(result.Identifiers) == 0 {
+		return ""
 	}
-	go func() {
-		reg := regexp.MustCompile(`(\d+),(.+)`)
-		defer supplierNameFile.Close()
-		scanner := bufio.NewScanner(supplierNameFile)
-		scanner.Scan() // skip the first line
-		for scanner.Scan() {
-			line := scanner.Text()
-			match := reg.FindStringSubmatch(line)
-			if len(match) != 3 {
-				log.Printf("invalid supplier name text: %s", line)
-				return
-			}
-			id := match[1]
-			supplierName := match[2]
-			supplierChan <- &Supplier{
-				Id:           id,
-				SupplierName: supplierName,
-			}
-		}
-		close(supplierChan)
-	}()
-	return
+	formatted := make([]string, 0, len(result.Identifiers))
+	for _, id := range result.Identifiers {
+		formatted = append(formatted, id.String())
+	}
+	return fmt.Sprintf(" (identifiers %s)", strings.Join(formatted, ","))
 }
 
-// loadInvoiceFile - load words of an invoice from file
-func loadInvoiceFile(invoiceFilePath string) (words []*Word, err error) {
-	// use regexp instead of json package because the file content is not valid JSON
-	reg := regexp.MustCompile(`'pos_id': (\d+), .+'word': '(.+)', 'line_id': (\d+), .+'page_id': (\d+),`)
-	invoiceFile, err := os.Open(invoiceFilePath)
-	if err != nil {
-		return
-	}
-	defer invoiceFile.Close()
-	words = make([]*Word, 0)
-	scanner := bufio.NewScanner(invoiceFile)
-	for scanner.Scan() {
-		line := scanner.Text()
-		match := reg.FindStringSubmatch(line)
-		if len(match) != 5 {
-			err = fmt.Errorf("invalid invoice text: %s", line)
-			return
-		}
-		posIdStr := match[1]
-		word := match[2]
-		lineIdStr := match[3]
-		pageIdStr := match[4]
-		posId, err := strconv.ParseUint(posIdStr, 10, 32)
-		if err != nil {
-			return nil, err
-		}
-		lineId, err := strconv.ParseUint(lineIdStr, 10, 32)
-		if err != nil {
-			return nil, err
-		}
-		pageId, err := strconv.ParseUint(pageIdStr, 10, 32)
-		if err != nil {
-			return nil, err
+// formatWords - format the matched words with their line id, position id and edit distance
+func formatWords(result *matcher.MatchResult) string {
+	formatted := make([]string, 0, len(result.Words))
+	for i, w := range result.Words {
+		if result.Distances[i] > 0 {
+			formatted = append(formatted, fmt.Sprintf("%s(line=%d,pos=%d,distance=%.1f)", w.Word, w.LineId, w.PosId, result.Distances[i]))
+			continue
 		}
-		words = append(words, &Word{
-			Word:   word,
-			PosId:  uint32(posId),
-			LineId: uint32(lineId),
-			PageId: uint32(pageId),
-		})
+		formatted = append(formatted, fmt.Sprintf("%s(line=%d,pos=%d)", w.Word, w.LineId, w.PosId))
 	}
-	return
+	return strings.Join(formatted, " ")
 }